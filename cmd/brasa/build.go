@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func buildCommand(args []string, stdin io.Reader, stderr io.Writer) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "path of the .brc file to write (default: the source path with the .brc extension)")
+	strip := flags.Bool("strip", false, "omit the debug info that maps instructions to source positions")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: brasa build [flags] <file.brasa | ->")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	out := *output
+	if out == "" {
+		if path == "-" {
+			fmt.Fprintln(stderr, "brasa: -o is required when reading from stdin")
+			return 2
+		}
+		out = strings.TrimSuffix(path, ".brasa") + ".brc"
+	}
+
+	name, src, err := readSource(path, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "brasa: %v\n", err)
+		return 1
+	}
+
+	chunk, ok := compileSource(name, src, stderr)
+	if !ok {
+		return 1
+	}
+
+	if *strip {
+		chunk.Positions = nil
+	} else {
+		chunk.SourceName = name
+	}
+
+	data, err := chunk.MarshalBinary()
+	if err == nil {
+		err = os.WriteFile(out, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "brasa: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -1,55 +1,62 @@
+// Command brasa is the command line entry point of the Brasa toolchain.
 package main
 
 import (
 	"fmt"
-	"log"
-
-	"github.com/rafa-ribeiro/brasalang/internal/compiler"
-	"github.com/rafa-ribeiro/brasalang/internal/parser"
-	"github.com/rafa-ribeiro/brasalang/internal/vm"
+	"io"
+	"os"
 )
 
-func main() {
-	fmt.Println("Brasa VM starting...")
-
-	sourceCode := `
+const usage = `usage: brasa <command> [arguments]
 
-	def double(x int) -> int {
-		return x * 2
-	}
+commands:
+  run [flags] <file | ->         run a source or .brc bytecode file, '-' reads from stdin
+  build [flags] <file.brasa | -> compile a source file to a .brc bytecode file
+  repl                           start an interactive session
 
-	def triple(x int) {
-		x * 3
-	}
+Run 'brasa <command> -h' for the flags of a command.
+`
 
-	a int = 2 * 5 
-	b int = 6 - 3
-	c int = ((a + b) * 3 - 4) * 2
-	
-	r int = double(c)
-	r 
-	`
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	p := parser.NewFromSource(sourceCode)
-	program := p.ParseProgram()
+// run executes the command line and returns the process exit status:
+// 0 on success, 1 when the program fails and 2 on invalid usage.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
 
-	if len(p.Errors()) > 0 {
-		log.Fatalf("parse errors: %v", p.Errors())
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:], stdin, stdout, stderr)
+	case "build":
+		return buildCommand(args[1:], stdin, stderr)
+	case "repl":
+		return replCommand(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "brasa: unknown command %q\n\n%s", args[0], usage)
+		return 2
 	}
+}
 
-	c := compiler.New()
-	chunk, err := c.Compile(program)
+// isTerminal reports whether w is an interactive terminal, honoring the NO_COLOR convention
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
 	if err != nil {
-		log.Fatalf("compile error: %v", err)
+		return false
 	}
-
-	fmt.Println("Bytecode:")
-	fmt.Println(chunk.Disassemble())
-
-	machine := vm.New()
-	machine.Run(chunk)
-
-	fmt.Printf("Source: %s\n", sourceCode)
-	fmt.Printf("Result: %s\n", machine.StackTop())
-
+	return info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunSourceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.brasa")
+	src := "def square(n int) -> int {\n\treturn n * n\n}\nsquare(7)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "", "run", "-result", path)
+	if code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != "49\n" {
+		t.Fatalf("expected result 49, got %q", stdout)
+	}
+}
+
+func TestRunReadsStdin(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "1 + 2\n", "run", "-result", "-")
+	if code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != "3\n" {
+		t.Fatalf("expected result 3, got %q", stdout)
+	}
+}
+
+func TestRunIsQuietByDefault(t *testing.T) {
+	code, stdout, _ := runCLI(t, "1 + 2\n", "run", "-")
+	if code != 0 || stdout != "" {
+		t.Fatalf("expected silent success, got status %d and output %q", code, stdout)
+	}
+}
+
+func TestRunShowsBytecode(t *testing.T) {
+	code, stdout, _ := runCLI(t, "1 + 2\n", "run", "-bytecode", "-")
+	if code != 0 {
+		t.Fatalf("expected exit status 0, got %d", code)
+	}
+	if !strings.Contains(stdout, "OP_ADD") {
+		t.Fatalf("expected disassembly in output, got %q", stdout)
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		stderr string
+	}{
+		{"parse error", "a int = \n", "error[PAR"},
+		{"semantic error", "a int = true\n", "error[SEM002]"},
+		{"runtime error", "a int = 0\n1 / a\n", "runtime error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(t, tt.src, "run", "-")
+			if code != 1 {
+				t.Fatalf("expected exit status 1, got %d", code)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Fatalf("expected %q in stderr, got %q", tt.stderr, stderr)
+			}
+		})
+	}
+}
+
+func TestRunMissingFile(t *testing.T) {
+	code, _, stderr := runCLI(t, "", "run", filepath.Join(t.TempDir(), "missing.brasa"))
+	if code != 1 || !strings.Contains(stderr, "missing.brasa") {
+		t.Fatalf("expected exit status 1 naming the file, got %d and %q", code, stderr)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{nil, {"frobnicate"}, {"run"}, {"run", "a.brasa", "b.brasa"}} {
+		if code, _, _ := runCLI(t, "", args...); code != 2 {
+			t.Fatalf("expected exit status 2 for %v, got %d", args, code)
+		}
+	}
+}
+
+func TestBuildThenRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.brasa")
+	src := "def half(n int) -> int {\n\treturn n / 2\n}\nhalf(84)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _, stderr := runCLI(t, "", "build", path); code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI(t, "", "run", "-result", filepath.Join(dir, "main.brc"))
+	if code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != "42\n" {
+		t.Fatalf("expected result 42, got %q", stdout)
+	}
+}
+
+func TestBuiltRuntimeErrorPointsAtSource(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.brc")
+	src := "def half(n int) -> int {\n\treturn 2 / n\n}\nhalf(0)\n"
+
+	if code, _, stderr := runCLI(t, src, "build", "-o", out, "-"); code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	code, _, stderr := runCLI(t, "", "run", out)
+	if code != 1 {
+		t.Fatalf("expected exit status 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "at half (<stdin>:2:11)") {
+		t.Fatalf("expected trace into the original source, got %q", stderr)
+	}
+
+	if code, _, _ := runCLI(t, src, "build", "-strip", "-o", out, "-"); code != 0 {
+		t.Fatalf("expected exit status 0, got %d", code)
+	}
+	if _, _, stderr := runCLI(t, "", "run", out); strings.Contains(stderr, "<stdin>") {
+		t.Fatalf("expected no source positions in a stripped build, got %q", stderr)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	if code, _, stderr := runCLI(t, "1\n", "build", "-"); code != 2 || !strings.Contains(stderr, "-o") {
+		t.Fatalf("expected usage error asking for -o, got %d and %q", code, stderr)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.brc")
+	if code, _, _ := runCLI(t, "a int = true\n", "build", "-o", out, "-"); code != 1 {
+		t.Fatalf("expected exit status 1 for an invalid program, got %d", code)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected no output file for an invalid program, got %v", err)
+	}
+
+	corrupt := append([]byte("\x7fBRC"), 0, 9)
+	if err := os.WriteFile(out, corrupt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCLI(t, "", "run", out); code != 1 || !strings.Contains(stderr, "invalid bytecode file") {
+		t.Fatalf("expected exit status 1 for a corrupt file, got %d and %q", code, stderr)
+	}
+}
+
+func TestRunVerifiesBytecodeFiles(t *testing.T) {
+	data, err := (&bytecode.Chunk{Code: []byte{byte(bytecode.OP_CONST), 5}}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bad.brc")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "", "run", path)
+	if code != 1 || !strings.Contains(stderr, "constant index 5 out of range") {
+		t.Fatalf("expected exit status 1 with a verifier error, got %d and %q", code, stderr)
+	}
+}
+
+func TestReplKeepsDeclarationsAcrossInputs(t *testing.T) {
+	input := "base int = 10\ndef add(a int, b int) -> int {\n\treturn a + b\n}\nadd(base, 5)\nbase = 1\nadd(base, base)\n"
+	code, stdout, stderr := runCLI(t, input, "repl")
+	if code != 0 {
+		t.Fatalf("expected exit status 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stderr != "" {
+		t.Fatalf("unexpected errors: %s", stderr)
+	}
+	if !strings.Contains(stdout, promptNested) {
+		t.Fatalf("expected a continuation prompt for the function body, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "15\n") || !strings.Contains(stdout, "2\n") {
+		t.Fatalf("expected the values of both calls, got %q", stdout)
+	}
+}
+
+func TestReplRecoversFromErrors(t *testing.T) {
+	input := "x int = true\nx int = 3\nx / 0\nx * 2\n"
+	_, stdout, stderr := runCLI(t, input, "repl")
+	if !strings.Contains(stderr, "error[SEM002]") || !strings.Contains(stderr, "runtime error") {
+		t.Fatalf("expected a type error and a runtime error, got %q", stderr)
+	}
+	if !strings.Contains(stdout, "6\n") {
+		t.Fatalf("expected the session to continue after the errors, got %q", stdout)
+	}
+}
+
+func TestReplMetaCommands(t *testing.T) {
+	input := "a int = 1\n:dis\n:tokens a + 2\n:ast a + 2\n:reset\na\n:nope\n:quit\n1 + 1\n"
+	_, stdout, stderr := runCLI(t, input, "repl")
+
+	for _, want := range []string{"OP_DEFINE_GLOBAL", "PLUS\t\"+\"", "BinaryExpr", "session reset"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected %q in output, got %q", want, stdout)
+		}
+	}
+	if !strings.Contains(stderr, `identifier "a" is not declared`) {
+		t.Errorf("expected :reset to forget declarations, got %q", stderr)
+	}
+	if !strings.Contains(stderr, "unknown command :nope") {
+		t.Errorf("expected unknown command error, got %q", stderr)
+	}
+	if !strings.HasSuffix(stdout, promptFirst) {
+		t.Errorf("expected :quit to end the session, got %q", stdout)
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"1 + 2\n", false},
+		{"def f() {\n", true},
+		{"def f() {\n}\n", false},
+		{"f(1,\n", true},
+		{"s string = \"{\"\n", false},
+		{"/* comment\n", true},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.src); got != tt.want {
+			t.Errorf("incomplete(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/compiler"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
+	"github.com/rafa-ribeiro/brasalang/internal/lexer"
+	"github.com/rafa-ribeiro/brasalang/internal/parser"
+	"github.com/rafa-ribeiro/brasalang/internal/semantic"
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+	"github.com/rafa-ribeiro/brasalang/internal/vm"
+)
+
+const (
+	replName     = "<repl>"
+	promptFirst  = ">> "
+	promptNested = ".. "
+)
+
+const replHelp = `:dis            show the bytecode of the last input
+:ast [code]     show the syntax tree of code, or of the last input
+:tokens [code]  show the tokens of code, or of the last input
+:reset          forget every declaration and start a new session
+:help           show this help
+:quit           leave the REPL
+`
+
+// repl is an interactive session. The analyzer, compiler and VM live as long as
+// the session, so declarations from previous inputs stay visible.
+type repl struct {
+	analyzer *semantic.Analyzer
+	compiler *compiler.Compiler
+	machine  *vm.VM
+
+	stdout, stderr io.Writer
+
+	lastSource string
+	lastChunk  *bytecode.Chunk
+}
+
+func replCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stderr, "usage: brasa repl")
+		return 2
+	}
+
+	r := &repl{stdout: stdout, stderr: stderr}
+	r.reset()
+
+	fmt.Fprintln(stdout, "Brasa REPL, type :help for the available commands")
+
+	scanner := bufio.NewScanner(stdin)
+	var input strings.Builder
+	for {
+		if input.Len() == 0 {
+			fmt.Fprint(stdout, promptFirst)
+		} else {
+			fmt.Fprint(stdout, promptNested)
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return 0
+		}
+		line := scanner.Text()
+
+		if input.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ":") {
+				if !r.command(trimmed) {
+					return 0
+				}
+				continue
+			}
+		}
+
+		input.WriteString(line)
+		input.WriteByte('\n')
+		if incomplete(input.String()) {
+			continue
+		}
+
+		r.eval(input.String())
+		input.Reset()
+	}
+}
+
+func (r *repl) reset() {
+	r.analyzer = semantic.New()
+	r.compiler = compiler.New()
+	r.machine = vm.New()
+	r.lastSource = ""
+	r.lastChunk = nil
+}
+
+// eval compiles and runs one complete input, printing the value of a trailing expression statement
+func (r *repl) eval(src string) {
+	program, chunk, ok := compileWith(r.analyzer, r.compiler, replName, src, r.stderr)
+	r.lastSource = src
+	if !ok {
+		return
+	}
+	r.lastChunk = chunk
+
+	if err := r.machine.Run(chunk); err != nil {
+		reportRuntimeError(r.stderr, replName, err)
+		return
+	}
+
+	if !endsWithExpression(program) {
+		return
+	}
+	if result, ok := r.machine.Result(); ok && result.Kind != value.NilKind {
+		fmt.Fprintln(r.stdout, result)
+	}
+}
+
+// command runs a meta command and reports whether the session continues
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		arg = r.lastSource
+	}
+
+	switch name {
+	case ":quit", ":q":
+		return false
+	case ":help":
+		fmt.Fprint(r.stdout, replHelp)
+	case ":reset":
+		r.reset()
+		fmt.Fprintln(r.stdout, "session reset")
+	case ":dis":
+		if r.lastChunk == nil {
+			fmt.Fprintln(r.stderr, "nothing compiled yet")
+			return true
+		}
+		fmt.Fprint(r.stdout, r.lastChunk.Disassemble())
+	case ":ast":
+		p := parser.NewFromSource(arg)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			diagnostics.NewPrinter(replName, arg, isTerminal(r.stderr)).Print(r.stderr, p.Errors())
+			return true
+		}
+		fmt.Fprint(r.stdout, ast.Dump(program))
+	case ":tokens":
+		for _, tok := range lexer.New(arg).Tokens() {
+			fmt.Fprintf(r.stdout, "%d:%d\t%s\t%q\n", tok.Position.Line, tok.Position.Column, tok.Type, tok.Lexeme)
+		}
+	default:
+		fmt.Fprintf(r.stderr, "unknown command %s, type :help for the available commands\n", name)
+	}
+	return true
+}
+
+// incomplete reports whether src still has unbalanced braces or parentheses,
+// or an unterminated block comment, so the REPL should keep reading lines.
+func incomplete(src string) bool {
+	l := lexer.New(src)
+
+	depth := 0
+	for _, tok := range l.Tokens() {
+		switch tok.Type {
+		case token.LBRACE, token.LPAREN:
+			depth++
+		case token.RBRACE, token.RPAREN:
+			depth--
+		}
+	}
+
+	for _, d := range l.Diagnostics() {
+		if d.Code == diagnostics.UnterminatedComment {
+			return true
+		}
+	}
+	return depth > 0
+}
+
+// endsWithExpression reports whether the last top-level statement, which leaves
+// its value on the stack, is an expression statement
+func endsWithExpression(program *ast.Program) bool {
+	for i := len(program.Statements) - 1; i >= 0; i-- {
+		switch program.Statements[i].(type) {
+		case *ast.FuncDeclStmt:
+			continue
+		case *ast.ExprStmt:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
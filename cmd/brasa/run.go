@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/compiler"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
+	"github.com/rafa-ribeiro/brasalang/internal/parser"
+	"github.com/rafa-ribeiro/brasalang/internal/semantic"
+	"github.com/rafa-ribeiro/brasalang/internal/vm"
+)
+
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	showBytecode := flags.Bool("bytecode", false, "print the disassembled bytecode before running")
+	showResult := flags.Bool("result", false, "print the value left on top of the stack when the program ends")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: brasa run [flags] <file.brasa | file.brc | ->")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name, src, err := readSource(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "brasa: %v\n", err)
+		return 1
+	}
+
+	chunk, ok := loadChunk(name, src, stderr)
+	if !ok {
+		return 1
+	}
+	if chunk.SourceName != "" {
+		name = chunk.SourceName
+	}
+
+	if *showBytecode {
+		fmt.Fprint(stdout, chunk.Disassemble())
+	}
+
+	machine := vm.New()
+	if err := machine.Run(chunk); err != nil {
+		reportRuntimeError(stderr, name, err)
+		return 1
+	}
+
+	if *showResult {
+		if result, ok := machine.Result(); ok {
+			fmt.Fprintln(stdout, result)
+		}
+	}
+	return 0
+}
+
+// readSource loads the program at path, or from stdin when path is "-"
+func readSource(path string, stdin io.Reader) (string, string, error) {
+	if path == "-" {
+		src, err := io.ReadAll(stdin)
+		return "<stdin>", string(src), err
+	}
+
+	src, err := os.ReadFile(path)
+	return path, string(src), err
+}
+
+// loadChunk decodes and verifies src when it holds a precompiled .brc file and
+// compiles it otherwise
+func loadChunk(name, src string, stderr io.Writer) (*bytecode.Chunk, bool) {
+	if !bytecode.IsBinary([]byte(src)) {
+		return compileSource(name, src, stderr)
+	}
+
+	chunk := &bytecode.Chunk{}
+	err := chunk.UnmarshalBinary([]byte(src))
+	if err == nil {
+		err = chunk.Verify()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "brasa: %s: %v\n", name, err)
+		return nil, false
+	}
+	return chunk, true
+}
+
+// compileSource runs every compilation phase over src, printing the diagnostics
+// of the first phase that fails to stderr.
+func compileSource(name, src string, stderr io.Writer) (*bytecode.Chunk, bool) {
+	_, chunk, ok := compileWith(semantic.New(), compiler.New(), name, src, stderr)
+	return chunk, ok
+}
+
+// compileWith is compileSource using the given analyzer and compiler, so the
+// declarations of previous sources stay visible. It also returns the parsed program.
+func compileWith(analyzer *semantic.Analyzer, comp *compiler.Compiler, name, src string, stderr io.Writer) (*ast.Program, *bytecode.Chunk, bool) {
+	printer := diagnostics.NewPrinter(name, src, isTerminal(stderr))
+
+	p := parser.NewFromSource(src)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		printer.Print(stderr, p.Errors())
+		return nil, nil, false
+	}
+
+	if errs := analyzer.Analyze(program); len(errs) > 0 {
+		printer.Print(stderr, errs)
+		return nil, nil, false
+	}
+
+	chunk, err := comp.Compile(program)
+	if err != nil {
+		var errs diagnostics.List
+		if errors.As(err, &errs) {
+			printer.Print(stderr, errs)
+		} else {
+			fmt.Fprintf(stderr, "compile error: %v\n", err)
+		}
+		return nil, nil, false
+	}
+
+	return program, chunk, true
+}
+
+// reportRuntimeError prints err together with the Brasa stack trace, when it
+// has one, pointing at positions in the source file name.
+func reportRuntimeError(stderr io.Writer, name string, err error) {
+	var runtimeErr *vm.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		fmt.Fprintf(stderr, "runtime error: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(stderr, "runtime error: %s\n", runtimeErr.Message)
+	for _, entry := range runtimeErr.Trace {
+		if entry.Position.Line == 0 {
+			fmt.Fprintf(stderr, "  at %s (%s)\n", entry.Function, entry.Location())
+			continue
+		}
+		fmt.Fprintf(stderr, "  at %s (%s:%s)\n", entry.Function, name, entry.Location())
+	}
+}
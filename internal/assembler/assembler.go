@@ -0,0 +1,427 @@
+// Package assembler builds bytecode chunks from text, so VM tests and patches
+// can be written by hand. It reads the output of Chunk.Disassemble, where every
+// instruction looks like
+//
+//	0004    2 OP_CONST              1 (6)
+//
+// and also accepts a terser syntax for hand-written code:
+//
+//	def square fn=0 arity=1 locals=1   ; function header, the function starts at the next instruction
+//	OP_GET_LOCAL 0
+//	OP_GET_LOCAL 0
+//	OP_MUL
+//	OP_RETURN
+//	main:                              ; label
+//	OP_CONST (7)                       ; constant added to the pool, or OP_CONST 0 (7) to pick its index
+//	OP_CALL square 1
+//	OP_JUMP_IF_FALSE main              ; jumps take a label or a raw offset
+//
+// The offset and the source line before the opcode are optional; a line of '|'
+// keeps the line of the previous instruction and '-' marks it as unknown.
+// Comments start with ';'.
+package assembler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
+
+// Error is a problem found in the line Line of the assembly source
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// instruction is an opcode parsed from a line, with operands still to resolve
+type instruction struct {
+	line     int
+	op       bytecode.OpCode
+	operands []string // raw operands, labels and function names included
+	constant *value.Value
+	position token.Position
+}
+
+// function is a function header together with the line declaring it
+type function struct {
+	line int
+	meta bytecode.FunctionMeta
+}
+
+type assembler struct {
+	instrs    []*instruction
+	labels    map[string]int // offset of each label
+	functions map[int]function
+	names     map[string]int // index of each function by name
+	constants map[int]value.Value
+	offset    int
+	position  token.Position
+}
+
+// Assemble parses src and returns the chunk it describes. The global count of
+// the chunk is one more than the highest global slot used by its code.
+func Assemble(src string) (*bytecode.Chunk, error) {
+	a := &assembler{
+		labels:    map[string]int{},
+		functions: map[int]function{},
+		names:     map[string]int{},
+		constants: map[int]value.Value{},
+	}
+
+	for n, line := range strings.Split(src, "\n") {
+		if err := a.parseLine(n+1, stripComment(line)); err != nil {
+			return nil, err
+		}
+	}
+	return a.emit()
+}
+
+// stripComment removes the ';' comment ending line, ignoring ';' in string constants
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func (a *assembler) parseLine(n int, line string) error {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return nil
+	case fields[0] == "def":
+		return a.parseFunction(n, fields[1:])
+	case len(fields) == 1 && strings.HasSuffix(fields[0], ":"):
+		label := strings.TrimSuffix(fields[0], ":")
+		if _, exists := a.labels[label]; exists {
+			return &Error{n, fmt.Sprintf("label %s already defined", label)}
+		}
+		a.labels[label] = a.offset
+		return nil
+	}
+
+	// the offset and source line printed by Disassemble come before the opcode
+	prefix := 0
+	for prefix < len(fields) && !strings.HasPrefix(fields[prefix], "OP_") {
+		prefix++
+	}
+	if prefix == len(fields) {
+		return &Error{n, fmt.Sprintf("expected an opcode, got %q", strings.TrimSpace(line))}
+	}
+	if prefix > 2 {
+		return &Error{n, fmt.Sprintf("unexpected %q before the opcode", fields[prefix-1])}
+	}
+	if prefix == 2 {
+		switch marker := fields[1]; marker {
+		case "|":
+		case "-":
+			a.position = token.Position{}
+		default:
+			srcLine, err := strconv.Atoi(marker)
+			if err != nil || srcLine <= 0 {
+				return &Error{n, fmt.Sprintf("invalid source line %q", marker)}
+			}
+			a.position = token.Position{Line: srcLine}
+		}
+	}
+
+	op, ok := bytecode.LookupOpCode(fields[prefix])
+	if !ok {
+		return &Error{n, fmt.Sprintf("unknown opcode %s", fields[prefix])}
+	}
+
+	in := &instruction{line: n, op: op, position: a.position}
+	rest := strings.TrimSpace(line[strings.Index(line, fields[prefix])+len(fields[prefix]):])
+	if err := a.parseOperands(in, rest); err != nil {
+		return err
+	}
+
+	a.instrs = append(a.instrs, in)
+	a.offset++
+	for _, width := range op.OperandWidths() {
+		a.offset += width
+	}
+	return nil
+}
+
+// parseFunction reads the header "name [fn=index] arity=n locals=n [private]"
+func (a *assembler) parseFunction(n int, fields []string) error {
+	if len(fields) == 0 {
+		return &Error{n, "missing function name"}
+	}
+	name := fields[0]
+	if _, exists := a.names[name]; exists {
+		return &Error{n, fmt.Sprintf("function %s already defined", name)}
+	}
+
+	index := len(a.functions)
+	meta := bytecode.FunctionMeta{Name: name, Entry: a.offset}
+	for _, field := range fields[1:] {
+		if field == "private" {
+			meta.Private = true
+			continue
+		}
+
+		key, raw, _ := strings.Cut(field, "=")
+		limit := bytecode.MaxIndex
+		if key == "arity" {
+			limit = bytecode.MaxCount
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 || v > limit {
+			return &Error{n, fmt.Sprintf("invalid function attribute %q", field)}
+		}
+
+		switch key {
+		case "fn":
+			index = v
+		case "arity":
+			meta.Arity = byte(v)
+		case "locals":
+			meta.LocalCount = v
+		default:
+			return &Error{n, fmt.Sprintf("unknown function attribute %q", field)}
+		}
+	}
+
+	if _, exists := a.functions[index]; exists {
+		return &Error{n, fmt.Sprintf("function index %d already used", index)}
+	}
+	a.functions[index] = function{line: n, meta: meta}
+	a.names[name] = index
+	return nil
+}
+
+// parseOperands splits rest in the operands of in. Constants are written as
+// "[index] (value)" and jumps may be followed by the "-> target" shown by Disassemble.
+func (a *assembler) parseOperands(in *instruction, rest string) error {
+	switch in.op {
+	case bytecode.OP_CONST, bytecode.OP_CONST_LONG:
+		raw, literal, hasValue := strings.Cut(rest, "(")
+		in.operands = strings.Fields(raw)
+		if !hasValue {
+			break
+		}
+
+		literal = strings.TrimSpace(literal)
+		if !strings.HasSuffix(literal, ")") {
+			return &Error{in.line, fmt.Sprintf("missing ')' after constant %s", literal)}
+		}
+		v, err := parseValue(strings.TrimSuffix(literal, ")"))
+		if err != nil {
+			return &Error{in.line, err.Error()}
+		}
+		in.constant = &v
+		if len(in.operands) > 1 {
+			return &Error{in.line, fmt.Sprintf("%s expects 1 operands, got %d", in.op, len(in.operands))}
+		}
+		return a.defineConstant(in)
+
+	case bytecode.OP_JUMP, bytecode.OP_JUMP_LONG, bytecode.OP_JUMP_IF_FALSE, bytecode.OP_JUMP_IF_TRUE, bytecode.OP_LOOP, bytecode.OP_RANGE_NEXT, bytecode.OP_ITER_NEXT:
+		target, _, _ := strings.Cut(rest, "->")
+		in.operands = strings.Fields(target)
+
+	default:
+		for _, field := range strings.Fields(rest) {
+			// keep the value of operands printed as key=value, like fn=0 argc=1
+			_, v, found := strings.Cut(field, "=")
+			if !found {
+				v = field
+			}
+			in.operands = append(in.operands, v)
+		}
+	}
+
+	if want := len(in.op.OperandWidths()); len(in.operands) != want {
+		return &Error{in.line, fmt.Sprintf("%s expects %d operands, got %d", in.op, want, len(in.operands))}
+	}
+	return nil
+}
+
+// defineConstant records the value of an instruction loading a constant at an explicit index
+func (a *assembler) defineConstant(in *instruction) error {
+	if len(in.operands) == 0 {
+		return nil
+	}
+	index, err := strconv.Atoi(in.operands[0])
+	if err != nil || index < 0 || index > bytecode.MaxIndex {
+		return &Error{in.line, fmt.Sprintf("invalid constant index %q", in.operands[0])}
+	}
+	if existing, ok := a.constants[index]; ok && !sameConstant(existing, *in.constant) {
+		return &Error{in.line, fmt.Sprintf("constant %d already defined as %s", index, existing)}
+	}
+	a.constants[index] = *in.constant
+	return nil
+}
+
+// parseValue reads a constant printed by Disassemble
+func parseValue(literal string) (value.Value, error) {
+	switch literal {
+	case "nil":
+		return value.NewNil(), nil
+	case "true", "false":
+		return value.NewBool(literal == "true"), nil
+	}
+
+	if strings.HasPrefix(literal, `"`) {
+		s, err := strconv.Unquote(literal)
+		if err != nil {
+			return value.Value{}, fmt.Errorf("invalid string constant %s", literal)
+		}
+		return value.NewString(s), nil
+	}
+	if i, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return value.NewInt(i), nil
+	}
+	if f, err := strconv.ParseFloat(literal, 64); err == nil {
+		return value.NewFloat(f), nil
+	}
+	return value.Value{}, fmt.Errorf("invalid constant %q", literal)
+}
+
+// sameConstant reports whether a and b are interchangeable in the constant pool
+func sameConstant(a, b value.Value) bool {
+	if a.Kind == value.FloatKind && b.Kind == value.FloatKind {
+		return math.Float64bits(a.F) == math.Float64bits(b.F)
+	}
+	return a.Kind == b.Kind && value.Equal(a, b)
+}
+
+// emit resolves the operands of every instruction and writes the chunk
+func (a *assembler) emit() (*bytecode.Chunk, error) {
+	chunk := &bytecode.Chunk{}
+
+	for index := range len(a.functions) {
+		fn, ok := a.functions[index]
+		if !ok {
+			return nil, a.missingFunction(index)
+		}
+		chunk.Functions = append(chunk.Functions, fn.meta)
+	}
+
+	for index := range poolSize(a.constants) {
+		v, ok := a.constants[index]
+		if !ok {
+			v = value.NewNil()
+		}
+		chunk.Constants = append(chunk.Constants, v)
+	}
+
+	for _, in := range a.instrs {
+		chunk.SetPosition(in.position)
+		chunk.Write(in.op)
+
+		next := len(chunk.Code)
+		for _, width := range in.op.OperandWidths() {
+			next += width
+		}
+
+		for i, width := range in.op.OperandWidths() {
+			v, err := a.resolve(chunk, in, i, next)
+			if err != nil {
+				return nil, err
+			}
+			if v < 0 || v >= 1<<(8*width) {
+				return nil, &Error{in.line, fmt.Sprintf("%s operand %d does not fit in %d bytes", in.op, v, width)}
+			}
+			for shift := 8 * (width - 1); shift >= 0; shift -= 8 {
+				chunk.WriteUint8(byte(v >> shift))
+			}
+		}
+
+		switch in.op {
+		case bytecode.OP_DEFINE_GLOBAL, bytecode.OP_DEFINE_GLOBAL_LONG, bytecode.OP_GET_GLOBAL, bytecode.OP_GET_GLOBAL_LONG, bytecode.OP_SET_GLOBAL, bytecode.OP_SET_GLOBAL_LONG:
+			slot, _ := strconv.Atoi(in.operands[0])
+			chunk.GlobalCount = max(chunk.GlobalCount, slot+1)
+		}
+	}
+	chunk.SetPosition(token.Position{})
+
+	return chunk, nil
+}
+
+// missingFunction reports the gap at index in the function table, at the
+// header of the first function declared with a higher index
+func (a *assembler) missingFunction(index int) *Error {
+	line := 0
+	for i, fn := range a.functions {
+		if i > index && (line == 0 || fn.line < line) {
+			line = fn.line
+		}
+	}
+	return &Error{line, fmt.Sprintf("function index %d is not defined", index)}
+}
+
+// resolve returns the value of the i-th operand of in, whose next instruction starts at next
+func (a *assembler) resolve(chunk *bytecode.Chunk, in *instruction, i, next int) (int, error) {
+	if in.constant != nil && len(in.operands) == 0 {
+		return addConstant(chunk, *in.constant), nil
+	}
+
+	raw := in.operands[i]
+	if v, err := strconv.Atoi(raw); err == nil {
+		if _, ok := a.constants[v]; !ok && (in.op == bytecode.OP_CONST || in.op == bytecode.OP_CONST_LONG) {
+			return 0, &Error{in.line, fmt.Sprintf("constant %d has no value, write it as %d (value)", v, v)}
+		}
+		return v, nil
+	}
+
+	switch in.op {
+	case bytecode.OP_JUMP, bytecode.OP_JUMP_LONG, bytecode.OP_JUMP_IF_FALSE, bytecode.OP_JUMP_IF_TRUE, bytecode.OP_RANGE_NEXT, bytecode.OP_ITER_NEXT:
+		if target, ok := a.labels[raw]; ok {
+			return target - next, nil
+		}
+		return 0, &Error{in.line, fmt.Sprintf("undefined label %s", raw)}
+	case bytecode.OP_LOOP:
+		if target, ok := a.labels[raw]; ok {
+			return next - target, nil
+		}
+		return 0, &Error{in.line, fmt.Sprintf("undefined label %s", raw)}
+	case bytecode.OP_CALL, bytecode.OP_CALL_LONG:
+		if index, ok := a.names[raw]; ok && i == 0 {
+			return index, nil
+		}
+		return 0, &Error{in.line, fmt.Sprintf("undefined function %s", raw)}
+	}
+	return 0, &Error{in.line, fmt.Sprintf("invalid operand %q", raw)}
+}
+
+// addConstant returns the index of v in the constant pool, adding it when missing
+func addConstant(chunk *bytecode.Chunk, v value.Value) int {
+	for i, existing := range chunk.Constants {
+		if sameConstant(existing, v) {
+			return i
+		}
+	}
+	return chunk.AddConstant(v)
+}
+
+// poolSize returns the number of slots needed to hold every index of constants
+func poolSize(constants map[int]value.Value) int {
+	n := 0
+	for index := range constants {
+		n = max(n, index+1)
+	}
+	return n
+}
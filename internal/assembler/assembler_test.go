@@ -0,0 +1,149 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/compiler"
+	"github.com/rafa-ribeiro/brasalang/internal/parser"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+	"github.com/rafa-ribeiro/brasalang/internal/vm"
+)
+
+func run(t *testing.T, chunk *bytecode.Chunk) value.Value {
+	t.Helper()
+
+	if err := chunk.Verify(); err != nil {
+		t.Fatalf("verify: %v\n%s", err, chunk.Disassemble())
+	}
+	machine := vm.New()
+	if err := machine.Run(chunk); err != nil {
+		t.Fatalf("runtime error: %v", err)
+	}
+	result, _ := machine.Result()
+	return result
+}
+
+func TestDisassemblyRoundTrips(t *testing.T) {
+	var long strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&long, "g%d float = %d.5\n", i, i)
+	}
+	long.WriteString("g299 + g0\n")
+
+	programs := []string{
+		"1 + 2 * 3\n",
+		"def _fact(n int) -> int {\n\tacc int = 1\n\twhile n > 1 {\n\t\tacc *= n\n\t\tn -= 1\n\t}\n\treturn acc\n}\n" +
+			"def greet(name string) -> string {\n\tif name == \"\" || name == \"; (x)\" {\n\t\treturn \"hi\"\n\t}\n\treturn \"hi, \" + name\n}\n" +
+			"greet(\"\\\"ana\\\"\\n\")\nx float = -0.0 / 2.5e30\n_fact(10)\n",
+		"def count(xs list[int]) -> int {\n\tn int = 0\n\tfor x in xs {\n\t\tfor i in 0..=x step 2 {\n\t\t\tn += i\n\t\t}\n\t}\n\treturn n\n}\n" +
+			"count([1, 4, 5])\n",
+		long.String(),
+	}
+
+	for _, src := range programs {
+		chunk, err := compiler.New().Compile(parser.NewFromSource(src).ParseProgram())
+		if err != nil {
+			t.Fatalf("compile error: %v", err)
+		}
+		text := chunk.Disassemble()
+
+		assembled, err := Assemble(text)
+		if err != nil {
+			t.Fatalf("assemble: %v\n%s", err, text)
+		}
+		if got := assembled.Disassemble(); got != text {
+			t.Fatalf("disassembly is not a fixed point\ngot:\n%s\nwant:\n%s", got, text)
+		}
+		if got, want := run(t, assembled), run(t, chunk); !value.Equal(got, want) {
+			t.Fatalf("assembled chunk returned %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAssembleHandWrittenCode(t *testing.T) {
+	src := `
+	; sum of the numbers from 1 to n
+	def sum arity=1 locals=2
+		OP_CONST (0)
+		OP_DEFINE_LOCAL 1
+	loop:
+		OP_GET_LOCAL 0
+		OP_CONST (0)
+		OP_GREATER
+		OP_JUMP_IF_FALSE done
+		OP_POP
+		OP_GET_LOCAL 1
+		OP_GET_LOCAL 0
+		OP_ADD
+		OP_SET_LOCAL 1
+		OP_GET_LOCAL 0
+		OP_CONST (1)
+		OP_SUB
+		OP_SET_LOCAL 0
+		OP_LOOP loop
+	done:
+		OP_POP
+		OP_GET_LOCAL 1
+		OP_RETURN
+
+	main:
+		OP_CONST 3 ("ignored; not a comment")
+		OP_POP
+		OP_CONST (100)
+		OP_CALL sum argc=1
+	`
+	// the function must be skipped over by main
+	chunk, err := Assemble("OP_JUMP_LONG main\n" + src)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+
+	if len(chunk.Constants) != 7 || chunk.Constants[3].S != "ignored; not a comment" {
+		t.Fatalf("unexpected constant pool: %v", chunk.Constants)
+	}
+	if fn := chunk.Functions[0]; fn.Name != "sum" || fn.Entry != 4 || fn.Arity != 1 || fn.LocalCount != 2 {
+		t.Fatalf("unexpected function: %+v", fn)
+	}
+	if result := run(t, chunk); result.Kind != value.IntKind || result.I != 5050 {
+		t.Fatalf("expected 5050, got %v", result)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		src    string
+		errMsg string
+	}{
+		{"OP_FROB", "line 1: unknown opcode OP_FROB"},
+		{"OP_TRUE\nOP_JUMP nowhere", "line 2: undefined label nowhere"},
+		{"OP_ADD 1", "OP_ADD expects 0 operands, got 1"},
+		{"OP_CALL 0", "OP_CALL expects 2 operands, got 1"},
+		{"OP_CONST 2", "constant 2 has no value"},
+		{"OP_CONST 0 (1)\nOP_CONST 0 (2)", "line 2: constant 0 already defined as 1"},
+		{"OP_CONST 300 (1)", "OP_CONST operand 300 does not fit in 1 bytes"},
+		{"OP_CONST (1.5", "missing ')'"},
+		{"OP_CONST (what)", `invalid constant "what"`},
+		{"a:\na:", "line 2: label a already defined"},
+		{"def f fn=1\nOP_RETURN", "line 1: function index 0 is not defined"},
+		{"def f arity=300", `invalid function attribute "arity=300"`},
+		{"0000 12 x OP_TRUE", `unexpected "x" before the opcode`},
+		{"0000 ? OP_TRUE", `invalid source line "?"`},
+		{"OP_CALL g 0", "undefined function g"},
+	}
+
+	for _, tt := range tests {
+		_, err := Assemble(tt.src)
+
+		var asmErr *Error
+		if !errors.As(err, &asmErr) {
+			t.Fatalf("%q: expected *Error, got %v", tt.src, err)
+		}
+		if !strings.Contains(err.Error(), tt.errMsg) {
+			t.Fatalf("%q: expected %q in %q", tt.src, tt.errMsg, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"strings"
+
 	"github.com/rafa-ribeiro/brasalang/internal/token"
 )
 
@@ -18,6 +20,13 @@ type Stmt interface {
 	stmtNode()
 }
 
+// TypeExpr is a type written in the source, such as int or (int, bool)
+type TypeExpr interface {
+	Node
+	typeNode()
+	String() string // the type as written in Brasa, with items separated by ", "
+}
+
 type Program struct {
 	Statements []Stmt
 }
@@ -33,6 +42,17 @@ func (node *IntLiteral) Pos() token.Position {
 
 func (node *IntLiteral) exprNode() {}
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (node *FloatLiteral) Pos() token.Position {
+	return node.Token.Position
+}
+
+func (node *FloatLiteral) exprNode() {}
+
 type BoolLiteral struct {
 	Token token.Token
 	Value bool
@@ -44,6 +64,18 @@ func (node *BoolLiteral) Pos() token.Position {
 
 func (node *BoolLiteral) exprNode() {}
 
+// StringLiteral holds the decoded value of a double-quoted string
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (node *StringLiteral) Pos() token.Position {
+	return node.Token.Position
+}
+
+func (node *StringLiteral) exprNode() {}
+
 type NilLiteral struct {
 	Token token.Token
 }
@@ -88,10 +120,32 @@ func (node *BinaryExpr) Pos() token.Position {
 
 func (node *BinaryExpr) exprNode() {}
 
+// RangeExpr is the sequence of ints from Low up to High by Step, as in
+// `0..n step 2`. High is only included when Operator is `..=`, and Step is nil
+// when omitted, meaning 1. Ranges are only written as the iterable of a ForStmt.
+type RangeExpr struct {
+	Low      Expr
+	Operator token.Token
+	High     Expr
+	Step     Expr
+}
+
+func (node *RangeExpr) Pos() token.Position {
+	return node.Operator.Position
+}
+
+func (node *RangeExpr) exprNode() {}
+
+// Inclusive reports whether High is one of the values of the range
+func (node *RangeExpr) Inclusive() bool {
+	return node.Operator.Type == token.DOT_DOT_EQUAL
+}
+
 // CallExpr represents a function call
 type CallExpr struct {
 	Callee    token.Token // the function to be called
 	Arguments []Expr
+	RParen    token.Token
 }
 
 func (node *CallExpr) Pos() token.Position {
@@ -100,6 +154,96 @@ func (node *CallExpr) Pos() token.Position {
 
 func (node *CallExpr) exprNode() {}
 
+// TupleLiteral builds a tuple from two or more values, as in `(1, true)`
+type TupleLiteral struct {
+	LParen token.Token
+	Items  []Expr
+	RParen token.Token
+}
+
+func (node *TupleLiteral) Pos() token.Position {
+	return node.LParen.Position
+}
+
+func (node *TupleLiteral) exprNode() {}
+
+// TupleIndexExpr reads the item of a tuple at a constant position, as in `t.0`
+type TupleIndexExpr struct {
+	Tuple Expr
+	Index token.Token // INT token with the position of the item
+	Value int
+}
+
+func (node *TupleIndexExpr) Pos() token.Position {
+	return node.Index.Position
+}
+
+func (node *TupleIndexExpr) exprNode() {}
+
+// ListLiteral builds a new list from its items, as in `[1, 2, 3]`
+type ListLiteral struct {
+	LBracket token.Token
+	Items    []Expr
+	RBracket token.Token
+}
+
+func (node *ListLiteral) Pos() token.Position {
+	return node.LBracket.Position
+}
+
+func (node *ListLiteral) exprNode() {}
+
+// MapLiteral builds a new map from its entries, as in `{"a": 1, "b": 2}`
+type MapLiteral struct {
+	LBrace  token.Token
+	Entries []MapEntry
+	RBrace  token.Token
+}
+
+// MapEntry is one `key: value` pair of a MapLiteral
+type MapEntry struct {
+	Key   Expr
+	Value Expr
+}
+
+func (node *MapLiteral) Pos() token.Position {
+	return node.LBrace.Position
+}
+
+func (node *MapLiteral) exprNode() {}
+
+// IndexExpr reads the item of a list at an index, or of a map at a key,
+// computed at run time, as in `xs[i]`
+type IndexExpr struct {
+	Collection Expr
+	LBracket   token.Token
+	Index      Expr
+	RBracket   token.Token
+}
+
+func (node *IndexExpr) Pos() token.Position {
+	return node.LBracket.Position
+}
+
+func (node *IndexExpr) exprNode() {}
+
+// SliceExpr builds a new list from the items of a list between two indexes, as
+// in `xs[a:b]`. Low and High are nil when omitted, meaning the start and the end
+// of the list.
+type SliceExpr struct {
+	Collection Expr
+	LBracket   token.Token
+	Low        Expr
+	High       Expr
+	RBracket   token.Token
+}
+
+func (node *SliceExpr) Pos() token.Position {
+	return node.LBracket.Position
+}
+
+func (node *SliceExpr) exprNode() {}
+
 type ExprStmt struct {
 	Expression Expr
 	Semicolon  token.Token
@@ -113,7 +257,7 @@ func (node *ExprStmt) stmtNode() {}
 
 type VarDeclStmt struct {
 	Name        token.Token
-	TypeName    token.Token
+	Type        TypeExpr
 	Initializer Expr
 }
 
@@ -123,6 +267,58 @@ func (node *VarDeclStmt) Pos() token.Position {
 
 func (node *VarDeclStmt) stmtNode() {}
 
+// DestructureTarget is one of the variables declared by a DestructureStmt. A
+// target named _ discards its value and has no type.
+type DestructureTarget struct {
+	Name token.Token
+	Type TypeExpr
+}
+
+// Discard reports whether the target is _, whose value is not stored
+func (t DestructureTarget) Discard() bool {
+	return t.Name.Lexeme == "_"
+}
+
+// DestructureStmt declares one variable for each item of a tuple, as in
+// `q int, r int = divmod(a, b)`
+type DestructureStmt struct {
+	Targets []DestructureTarget
+	Value   Expr
+}
+
+func (node *DestructureStmt) Pos() token.Position {
+	return node.Targets[0].Name.Position
+}
+
+func (node *DestructureStmt) stmtNode() {}
+
+// AssignStmt represents `name = value` and compound forms such as `name += value`
+type AssignStmt struct {
+	Name     token.Token
+	Operator token.Token
+	Value    Expr
+}
+
+func (node *AssignStmt) Pos() token.Position {
+	return node.Name.Position
+}
+
+func (node *AssignStmt) stmtNode() {}
+
+// IndexAssignStmt stores a value in a collection, as in `xs[i] = value` and
+// compound forms such as `xs[i] += value`
+type IndexAssignStmt struct {
+	Target   *IndexExpr
+	Operator token.Token
+	Value    Expr
+}
+
+func (node *IndexAssignStmt) Pos() token.Position {
+	return node.Target.Pos()
+}
+
+func (node *IndexAssignStmt) stmtNode() {}
+
 type BlockStmt struct {
 	LBrace     token.Token
 	Statements []Stmt
@@ -134,6 +330,72 @@ func (node *BlockStmt) Pos() token.Position {
 
 func (node *BlockStmt) stmtNode() {}
 
+// IfStmt represents a conditional statement. Else is either nil, a *BlockStmt
+// for a plain else branch, or another *IfStmt for an else-if chain.
+type IfStmt struct {
+	If        token.Token
+	Condition Expr
+	Then      *BlockStmt
+	Else      Stmt
+}
+
+func (node *IfStmt) Pos() token.Position {
+	return node.If.Position
+}
+
+func (node *IfStmt) stmtNode() {}
+
+// WhileStmt represents a loop that runs its body while the condition holds
+type WhileStmt struct {
+	While     token.Token
+	Condition Expr
+	Body      *BlockStmt
+}
+
+func (node *WhileStmt) Pos() token.Position {
+	return node.While.Position
+}
+
+func (node *WhileStmt) stmtNode() {}
+
+// ForStmt runs its body once for each value of Iterable, which is a RangeExpr
+// or a collection, with the value stored in the variable Name. The variable is
+// only visible inside the body.
+type ForStmt struct {
+	For      token.Token
+	Name     token.Token
+	Iterable Expr
+	Body     *BlockStmt
+}
+
+func (node *ForStmt) Pos() token.Position {
+	return node.For.Position
+}
+
+func (node *ForStmt) stmtNode() {}
+
+// BreakStmt exits the innermost enclosing loop
+type BreakStmt struct {
+	Break token.Token
+}
+
+func (node *BreakStmt) Pos() token.Position {
+	return node.Break.Position
+}
+
+func (node *BreakStmt) stmtNode() {}
+
+// ContinueStmt skips to the next iteration of the innermost enclosing loop
+type ContinueStmt struct {
+	Continue token.Token
+}
+
+func (node *ContinueStmt) Pos() token.Position {
+	return node.Continue.Position
+}
+
+func (node *ContinueStmt) stmtNode() {}
+
 // ReturnStmt represents the keyword return used in functions
 type ReturnStmt struct {
 	Return token.Token
@@ -149,15 +411,16 @@ func (node *ReturnStmt) stmtNode() {}
 // Param defines a function parameter
 type Param struct {
 	Name token.Token
-	Type token.Token
+	Type TypeExpr
 }
 
-// FuncDeclStmt represents a function definition
+// FuncDeclStmt represents a function definition. A function with several
+// return types returns them as a tuple.
 type FuncDeclStmt struct {
 	DefToken    token.Token
 	Name        token.Token
 	Params      []Param
-	ReturnTypes []token.Token
+	ReturnTypes []TypeExpr
 	Body        *BlockStmt
 	Private     bool
 }
@@ -167,3 +430,77 @@ func (node *FuncDeclStmt) Pos() token.Position {
 }
 
 func (node *FuncDeclStmt) stmtNode() {}
+
+// NamedType is a type referred to by its name, such as int
+type NamedType struct {
+	Name token.Token
+}
+
+func (node *NamedType) Pos() token.Position {
+	return node.Name.Position
+}
+
+func (node *NamedType) typeNode() {}
+
+func (node *NamedType) String() string {
+	return node.Name.Lexeme
+}
+
+// TupleType is the type of tuples with items of the given types, as in `(int, bool)`
+type TupleType struct {
+	LParen token.Token
+	Items  []TypeExpr
+	RParen token.Token
+}
+
+func (node *TupleType) Pos() token.Position {
+	return node.LParen.Position
+}
+
+func (node *TupleType) typeNode() {}
+
+func (node *TupleType) String() string {
+	items := make([]string, len(node.Items))
+	for i, item := range node.Items {
+		items[i] = item.String()
+	}
+	return "(" + strings.Join(items, ", ") + ")"
+}
+
+// ListType is the type of lists holding items of type Elem, as in `list[int]`
+type ListType struct {
+	Name     token.Token
+	LBracket token.Token
+	Elem     TypeExpr
+	RBracket token.Token
+}
+
+func (node *ListType) Pos() token.Position {
+	return node.Name.Position
+}
+
+func (node *ListType) typeNode() {}
+
+func (node *ListType) String() string {
+	return "list[" + node.Elem.String() + "]"
+}
+
+// MapType is the type of maps from keys of type Key to values of type Value, as
+// in `map[string]int`
+type MapType struct {
+	Name     token.Token
+	LBracket token.Token
+	Key      TypeExpr
+	RBracket token.Token
+	Value    TypeExpr
+}
+
+func (node *MapType) Pos() token.Position {
+	return node.Name.Position
+}
+
+func (node *MapType) typeNode() {}
+
+func (node *MapType) String() string {
+	return "map[" + node.Key.String() + "]" + node.Value.String()
+}
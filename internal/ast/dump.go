@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// Dump renders the node as an indented tree with one field per line, e.g.
+//
+//	VarDeclStmt
+//	  Name: x
+//	  Type: int
+//	  Initializer: IntLiteral
+//	    Token: 1
+//	    Value: 1
+//
+// Tokens are shown by their lexeme, types as written in Brasa and fields holding
+// a zero token or a nil node are omitted.
+func Dump(node any) string {
+	var b strings.Builder
+	dumpValue(&b, reflect.ValueOf(node), 0)
+	return b.String()
+}
+
+func dumpValue(b *strings.Builder, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			b.WriteString("nil\n")
+			return
+		}
+		if typ, ok := v.Interface().(TypeExpr); ok {
+			b.WriteString(typ.String() + "\n")
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || v.Type() == tokenType {
+		b.WriteString(scalar(v) + "\n")
+		return
+	}
+
+	b.WriteString(v.Type().Name() + "\n")
+	indent := strings.Repeat("  ", depth+1)
+	for i := 0; i < v.NumField(); i++ {
+		name, field := v.Type().Field(i).Name, v.Field(i)
+		if field.IsZero() {
+			continue
+		}
+
+		if field.Kind() == reflect.Slice {
+			for j := 0; j < field.Len(); j++ {
+				fmt.Fprintf(b, "%s%s[%d]: ", indent, name, j)
+				dumpValue(b, field.Index(j), depth+1)
+			}
+			continue
+		}
+
+		fmt.Fprintf(b, "%s%s: ", indent, name)
+		dumpValue(b, field, depth+1)
+	}
+}
+
+func scalar(v reflect.Value) string {
+	switch v.Type() {
+	case tokenType:
+		return v.Interface().(token.Token).Lexeme
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
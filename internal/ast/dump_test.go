@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+func TestDump(t *testing.T) {
+	node := &VarDeclStmt{
+		Name: token.Token{Type: token.IDENT, Lexeme: "x"},
+		Type: &TupleType{Items: []TypeExpr{
+			&NamedType{Name: token.Token{Type: token.IDENT, Lexeme: "int"}},
+			&NamedType{Name: token.Token{Type: token.IDENT, Lexeme: "bool"}},
+		}},
+		Initializer: &BinaryExpr{
+			Left:     &IntLiteral{Token: token.Token{Type: token.INT, Lexeme: "1"}, Value: 1},
+			Operator: token.Token{Type: token.PLUS, Lexeme: "+"},
+			Right:    &Identifier{Token: token.Token{Type: token.IDENT, Lexeme: "y"}, Name: "y"},
+		},
+	}
+
+	want := `VarDeclStmt
+  Name: x
+  Type: (int, bool)
+  Initializer: BinaryExpr
+    Left: IntLiteral
+      Token: 1
+      Value: 1
+    Operator: +
+    Right: Identifier
+      Token: y
+      Name: "y"
+`
+	if got := Dump(node); got != want {
+		t.Fatalf("unexpected dump:\n%s\nwant:\n%s", got, want)
+	}
+}
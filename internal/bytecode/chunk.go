@@ -1,37 +1,139 @@
 package bytecode
 
-import "github.com/rafa-ribeiro/brasalang/internal/value"
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
 
 const JumpInstructionOperandWidth int = 2
 
+const (
+	// MaxShortIndex is the largest index that fits the single byte operand of the short opcodes
+	MaxShortIndex = math.MaxUint8
+	// MaxIndex is the largest constant, global, local or function index, encoded by the _LONG opcodes
+	MaxIndex = 1<<24 - 1
+	// MaxJump is the largest distance covered by OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE and OP_LOOP
+	MaxJump = math.MaxUint16
+	// MaxLongJump is the largest distance covered by OP_JUMP_LONG
+	MaxLongJump = 1<<24 - 1
+	// MaxCount is the largest number of arguments, parameters or tuple items
+	MaxCount = math.MaxUint8
+)
+
 type Chunk struct {
-	Code      []byte
-	Constants []value.Value
-	Functions []FunctionMeta
+	Code        []byte
+	Constants   []value.Value
+	Functions   []FunctionMeta
+	GlobalCount int           // Number of global slots the code may use, including those of earlier runs
+	Positions   []PositionRun // Source positions of the instructions, run-length encoded
+	SourceName  string        // Name of the source file the chunk was compiled from, if known
+
+	position token.Position // Position recorded for the next instructions written
+}
+
+// PositionRun marks that the instructions from Offset up to the Offset of the
+// next run were compiled from the source at Position.
+type PositionRun struct {
+	Offset   int
+	Position token.Position
 }
 
 // FunctionMeta represents the required information to execute a specific function inside the VM
 type FunctionMeta struct {
 	Name       string // Function name
 	Arity      byte   // Number of arguments
-	Entry      uint16 // Index of the bytecode (Chunk.Code) where the function starts
-	LocalCount byte   // Count of local required variables
+	Entry      int    // Index of the bytecode (Chunk.Code) where the function starts
+	LocalCount int    // Count of local required variables
 	Private    bool   // Visibility
 }
 
 // Write the provided OpCode to the Chunk
 func (c *Chunk) Write(op OpCode) {
+	c.recordPosition()
 	c.Code = append(c.Code, byte(op))
 }
 
-func (c *Chunk) WriteByte(b byte) {
+// SetPosition sets the source position of the instructions written next and
+// returns the previous one, so callers can restore it.
+func (c *Chunk) SetPosition(pos token.Position) token.Position {
+	previous := c.position
+	c.position = pos
+	return previous
+}
+
+// recordPosition starts a new run when the current position differs from the last one
+func (c *Chunk) recordPosition() {
+	if len(c.Positions) == 0 {
+		if c.position == (token.Position{}) {
+			return
+		}
+	} else if c.Positions[len(c.Positions)-1].Position == c.position {
+		return
+	}
+	c.Positions = append(c.Positions, PositionRun{Offset: len(c.Code), Position: c.position})
+}
+
+// PositionAt returns the source position of the instruction at offset. It
+// reports false when the chunk has no position recorded for it.
+func (c *Chunk) PositionAt(offset int) (token.Position, bool) {
+	i := sort.Search(len(c.Positions), func(i int) bool { return c.Positions[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}, false
+	}
+	pos := c.Positions[i-1].Position
+	return pos, pos != (token.Position{})
+}
+
+// WriteUint8 writes a single byte operand
+func (c *Chunk) WriteUint8(b byte) {
 	c.Code = append(c.Code, b)
 }
 
-func (c *Chunk) WriteConst(v value.Value) {
-	index := c.AddConstant(v)
-	c.Write(OP_CONST)
-	c.WriteByte(byte(index))
+// WriteUint24 writes a 24-bit big-endian operand
+func (c *Chunk) WriteUint24(v int) {
+	c.Code = append(c.Code, byte(v>>16), byte(v>>8), byte(v))
+}
+
+// WriteConst adds v to the constant pool and writes the instruction loading it
+func (c *Chunk) WriteConst(v value.Value) error {
+	return c.WriteIndexed(OP_CONST, c.AddConstant(v))
+}
+
+// WriteIndexed writes op with an index operand, switching to the _LONG variant
+// of op when the index does not fit in a single byte.
+func (c *Chunk) WriteIndexed(op OpCode, index int) error {
+	if index <= MaxShortIndex {
+		c.Write(op)
+		c.WriteUint8(byte(index))
+		return nil
+	}
+
+	long, ok := op.Long()
+	if !ok {
+		return fmt.Errorf("%s operand %d exceeds the limit of %d", op, index, MaxShortIndex)
+	}
+	if index > MaxIndex {
+		return fmt.Errorf("%s operand %d exceeds the limit of %d", op, index, MaxIndex)
+	}
+	c.Write(long)
+	c.WriteUint24(index)
+	return nil
+}
+
+// WriteCall writes a call to the function at fnIndex with argc arguments
+func (c *Chunk) WriteCall(fnIndex, argc int) error {
+	if argc > MaxCount {
+		return fmt.Errorf("call with %d arguments exceeds the limit of %d", argc, MaxCount)
+	}
+	if err := c.WriteIndexed(OP_CALL, fnIndex); err != nil {
+		return err
+	}
+	c.WriteUint8(byte(argc))
+	return nil
 }
 
 func (c *Chunk) AddConstant(v value.Value) int {
@@ -42,16 +144,48 @@ func (c *Chunk) AddConstant(v value.Value) int {
 func (c *Chunk) EmitJump(op OpCode) int {
 	c.Write(op)
 
-	// reserve two bytes for offset
-	c.WriteByte(0)
-	c.WriteByte(0)
+	// reserve the bytes for offset
+	width := op.OperandWidths()[0]
+	for range width {
+		c.WriteUint8(0)
+	}
 
-	return len(c.Code) - JumpInstructionOperandWidth // posição do primeiro byte do offset
+	return len(c.Code) - width // posição do primeiro byte do offset
 }
 
-func (c *Chunk) PatchJump(offsetPos int) {
+// PatchJump makes the jump whose offset starts at offsetPos land on the next
+// instruction written. It fails when the distance does not fit the jump operand.
+func (c *Chunk) PatchJump(offsetPos int) error {
+	if OpCode(c.Code[offsetPos-1]) == OP_JUMP_LONG {
+		jump := len(c.Code) - (offsetPos + 3)
+		if jump > MaxLongJump {
+			return fmt.Errorf("jump of %d bytes exceeds the limit of %d", jump, MaxLongJump)
+		}
+		c.Code[offsetPos] = byte(jump >> 16)
+		c.Code[offsetPos+1] = byte(jump >> 8)
+		c.Code[offsetPos+2] = byte(jump)
+		return nil
+	}
+
 	jump := len(c.Code) - (offsetPos + JumpInstructionOperandWidth)
+	if jump > MaxJump {
+		return fmt.Errorf("jump of %d bytes exceeds the limit of %d", jump, MaxJump)
+	}
 
 	c.Code[offsetPos] = byte(jump >> 8)
 	c.Code[offsetPos+1] = byte(jump & 0xff)
+	return nil
+}
+
+// EmitLoop writes an OP_LOOP that jumps back to loopStart
+func (c *Chunk) EmitLoop(loopStart int) error {
+	offset := len(c.Code) + 1 + JumpInstructionOperandWidth - loopStart
+	if offset > MaxJump {
+		return fmt.Errorf("loop body of %d bytes exceeds the limit of %d", offset, MaxJump)
+	}
+
+	c.Write(OP_LOOP)
+	c.WriteUint8(byte(offset >> 8))
+	c.WriteUint8(byte(offset & 0xff))
+	return nil
 }
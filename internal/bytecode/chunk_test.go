@@ -0,0 +1,96 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+func TestPositionsAreRunLengthEncoded(t *testing.T) {
+	chunk := &Chunk{}
+	line := func(n int) token.Position { return token.Position{Line: n, Column: 1} }
+
+	chunk.Write(OP_TRUE) // no position yet
+	chunk.SetPosition(line(1))
+	chunk.Write(OP_TRUE)
+	chunk.Write(OP_POP)
+	previous := chunk.SetPosition(line(2))
+	chunk.EmitJump(OP_JUMP)
+	chunk.SetPosition(previous)
+	chunk.Write(OP_FALSE)
+
+	want := []PositionRun{{Offset: 1, Position: line(1)}, {Offset: 3, Position: line(2)}, {Offset: 6, Position: line(1)}}
+	if len(chunk.Positions) != len(want) {
+		t.Fatalf("unexpected runs: %+v", chunk.Positions)
+	}
+	for i := range want {
+		if chunk.Positions[i] != want[i] {
+			t.Fatalf("unexpected runs: %+v", chunk.Positions)
+		}
+	}
+
+	tests := []struct {
+		offset int
+		line   int
+		ok     bool
+	}{
+		{0, 0, false},
+		{1, 1, true},
+		{2, 1, true},
+		{4, 2, true}, // jump operand
+		{6, 1, true},
+		{100, 1, true},
+	}
+	for _, tt := range tests {
+		pos, ok := chunk.PositionAt(tt.offset)
+		if ok != tt.ok || pos.Line != tt.line {
+			t.Errorf("PositionAt(%d) = %v, %v; want line %d, %v", tt.offset, pos, ok, tt.line, tt.ok)
+		}
+	}
+}
+
+func TestWriteIndexedPicksOperandWidth(t *testing.T) {
+	chunk := &Chunk{}
+
+	if err := chunk.WriteIndexed(OP_GET_GLOBAL, MaxShortIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := chunk.WriteIndexed(OP_GET_GLOBAL, MaxShortIndex+1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{byte(OP_GET_GLOBAL), 0xff, byte(OP_GET_GLOBAL_LONG), 0x00, 0x01, 0x00}
+	if string(chunk.Code) != string(want) {
+		t.Fatalf("unexpected code % x", chunk.Code)
+	}
+}
+
+func TestHardLimitsAreErrors(t *testing.T) {
+	chunk := &Chunk{}
+
+	if err := chunk.WriteIndexed(OP_SET_LOCAL, MaxIndex+1); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Fatalf("expected index limit error, got %v", err)
+	}
+	if err := chunk.WriteIndexed(OP_BUILD_TUPLE, 300); err == nil {
+		t.Fatal("expected error for an opcode without long form")
+	}
+	if err := chunk.WriteCall(0, MaxCount+1); err == nil {
+		t.Fatal("expected argument count error")
+	}
+
+	jump := chunk.EmitJump(OP_JUMP)
+	chunk.Code = append(chunk.Code, make([]byte, MaxJump+1)...)
+	if err := chunk.PatchJump(jump); err == nil {
+		t.Fatal("expected jump distance error")
+	}
+	if err := chunk.EmitLoop(0); err == nil {
+		t.Fatal("expected loop distance error")
+	}
+
+	long := chunk.EmitJump(OP_JUMP_LONG)
+	chunk.Code = append(chunk.Code, make([]byte, MaxJump+1)...)
+	if err := chunk.PatchJump(long); err != nil {
+		t.Fatalf("unexpected error for long jump: %v", err)
+	}
+}
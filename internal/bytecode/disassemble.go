@@ -2,74 +2,116 @@ package bytecode
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/value"
 )
 
 // Disassemble returns a human-readable view of the chunk opcodes.
 // It is useful while evolving the compiler/VM because it makes control-flow
-// and constants easy to inspect.
+// and constants easy to inspect. When the chunk has positions, the source line
+// of each instruction is shown after its offset, or '|' for the same line as the
+// previous instruction. Each function starts with a header line naming it and
+// showing its index, arity and local count.
 func (c *Chunk) Disassemble() string {
 	var out strings.Builder
 	i := 0
+	lastLine := 0
+
+	entries := map[int][]int{}
+	for fn, meta := range c.Functions {
+		entries[meta.Entry] = append(entries[meta.Entry], fn)
+	}
 
 	for i < len(c.Code) {
+		for _, fn := range entries[i] {
+			meta := c.Functions[fn]
+			fmt.Fprintf(&out, "def %s fn=%d arity=%d locals=%d", meta.Name, fn, meta.Arity, meta.LocalCount)
+			if meta.Private {
+				out.WriteString(" private")
+			}
+			out.WriteByte('\n')
+		}
+
 		op := OpCode(c.Code[i])
-		fmt.Fprintf(&out, "%04d %-16s", i, op)
+		fmt.Fprintf(&out, "%04d ", i)
+		if len(c.Positions) > 0 {
+			pos, ok := c.PositionAt(i)
+			switch {
+			case !ok:
+				out.WriteString("   - ")
+			case pos.Line == lastLine:
+				out.WriteString("   | ")
+			default:
+				fmt.Fprintf(&out, "%4d ", pos.Line)
+			}
+			lastLine = pos.Line
+		}
+		fmt.Fprintf(&out, "%-22s", op)
 		i++
 
-		switch op {
-		case OP_CONST, OP_DEFINE_GLOBAL, OP_GET_GLOBAL, OP_DEFINE_LOCAL, OP_GET_LOCAL, OP_BUILD_TUPLE:
-			if i >= len(c.Code) {
-				out.WriteString("<missing operand>\n")
-				continue
+		widths := op.OperandWidths()
+		operands := make([]int, len(widths))
+		missing := false
+		for n, width := range widths {
+			if i+width > len(c.Code) {
+				missing = true
+				break
 			}
-			idx := int(c.Code[i])
-			i++
-
-			if op == OP_CONST {
-				if idx < 0 || idx >= len(c.Constants) {
-					fmt.Fprintf(&out, "%d <invalid const index>\n", idx)
-					continue
-				}
-				fmt.Fprintf(&out, "%d (%s)\n", idx, c.Constants[idx])
+			operands[n] = readOperand(c.Code[i:], width)
+			i += width
+		}
+		if missing {
+			out.WriteString("<missing operand>\n")
+			break
+		}
+
+		switch op {
+		case OP_CONST, OP_CONST_LONG:
+			idx := operands[0]
+			if idx >= len(c.Constants) {
+				fmt.Fprintf(&out, "%d <invalid const index>\n", idx)
 				continue
 			}
-
-			if op == OP_BUILD_TUPLE {
-				fmt.Fprintf(&out, "count=%d\n", idx)
+			constant := c.Constants[idx]
+			if constant.Kind == value.StringKind {
+				fmt.Fprintf(&out, "%d (%s)\n", idx, strconv.Quote(constant.S))
 				continue
 			}
+			fmt.Fprintf(&out, "%d (%s)\n", idx, constant)
 
-			fmt.Fprintf(&out, "%d\n", idx)
+		case OP_BUILD_TUPLE, OP_UNPACK_TUPLE, OP_BUILD_LIST, OP_BUILD_MAP:
+			fmt.Fprintf(&out, "count=%d\n", operands[0])
 
-		case OP_CALL:
-			if i+1 >= len(c.Code) {
-				out.WriteString("<missing call operands>\n")
-				continue
-			}
+		case OP_GET_TUPLE_ITEM:
+			fmt.Fprintf(&out, "index=%d\n", operands[0])
 
-			fnIdx := c.Code[i]
-			argc := c.Code[i+1]
-			i += 2
-			fmt.Fprintf(&out, "fn=%d argc=%d\n", fnIdx, argc)
+		case OP_CALL, OP_CALL_LONG:
+			fmt.Fprintf(&out, "fn=%d argc=%d\n", operands[0], operands[1])
 
-		case OP_JUMP, OP_JUMP_IF_FALSE:
-			if i+1 >= len(c.Code) {
-				out.WriteString("<missing jump offset>\n")
-				continue
-			}
+		case OP_JUMP, OP_JUMP_LONG, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE, OP_RANGE_NEXT, OP_ITER_NEXT:
+			fmt.Fprintf(&out, "%d -> %04d\n", operands[0], i+operands[0])
 
-			offset := (uint16(c.Code[i]) << 8) | uint16(c.Code[i+1])
-			target := i + JumpInstructionOperandWidth + int(offset)
-			i += JumpInstructionOperandWidth
-			fmt.Fprintf(&out, "%d -> %04d\n", offset, target)
+		case OP_LOOP:
+			fmt.Fprintf(&out, "%d -> %04d\n", operands[0], i-operands[0])
 
 		default:
+			if len(operands) > 0 {
+				fmt.Fprintf(&out, "%d", operands[0])
+			}
 			out.WriteByte('\n')
-
 		}
 	}
 
 	return out.String()
+}
 
+// readOperand decodes a big-endian operand of width bytes from the start of code
+func readOperand(code []byte, width int) int {
+	v := 0
+	for _, b := range code[:width] {
+		v = v<<8 | int(b)
+	}
+	return v
 }
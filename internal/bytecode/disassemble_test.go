@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/rafa-ribeiro/brasalang/internal/token"
 	"github.com/rafa-ribeiro/brasalang/internal/value"
 )
 
@@ -37,3 +38,77 @@ func TestDisassembleIncludesConstantsAndJumpTargets(t *testing.T) {
 		}
 	}
 }
+
+func TestDisassembleShowsLoopTarget(t *testing.T) {
+	chunk := &Chunk{}
+
+	chunk.Write(OP_TRUE)
+	chunk.Write(OP_POP)
+	chunk.EmitLoop(0)
+
+	got := chunk.Disassemble()
+	if !strings.Contains(got, "OP_LOOP") || !strings.Contains(got, "5 -> 0000") {
+		t.Fatalf("unexpected loop disassembly\n%s", got)
+	}
+}
+
+func TestDisassembleQuotesStringConstants(t *testing.T) {
+	chunk := &Chunk{}
+	chunk.WriteConst(value.NewString("oi\n"))
+
+	got := chunk.Disassemble()
+	if !strings.Contains(got, `0 ("oi\n")`) {
+		t.Fatalf("disassembly missing quoted string constant\n%s", got)
+	}
+}
+
+func TestDisassembleShowsSourceLines(t *testing.T) {
+	chunk := &Chunk{}
+
+	chunk.SetPosition(token.Position{Line: 1, Column: 1})
+	chunk.WriteConst(value.NewInt(1))
+	chunk.Write(OP_POP)
+	chunk.SetPosition(token.Position{Line: 3, Column: 5})
+	chunk.Write(OP_TRUE)
+
+	want := "0000    1 OP_CONST              0 (1)\n" +
+		"0002    | OP_POP                \n" +
+		"0003    3 OP_TRUE               \n"
+	if got := chunk.Disassemble(); got != want {
+		t.Fatalf("unexpected disassembly\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDisassembleLongOperands(t *testing.T) {
+	chunk := &Chunk{Constants: make([]value.Value, 300)}
+	chunk.Constants[299] = value.NewInt(7)
+
+	chunk.WriteIndexed(OP_CONST, 299)
+	chunk.WriteIndexed(OP_DEFINE_GLOBAL, 70000)
+	chunk.WriteCall(256, 2)
+	jump := chunk.EmitJump(OP_JUMP_LONG)
+	chunk.Write(OP_POP)
+	chunk.PatchJump(jump)
+
+	got := chunk.Disassemble()
+	for _, check := range []string{"0000 OP_CONST_LONG         299 (7)", "OP_DEFINE_GLOBAL_LONG 70000", "OP_CALL_LONG          fn=256 argc=2", "OP_JUMP_LONG          1 -> 0018"} {
+		if !strings.Contains(got, check) {
+			t.Fatalf("disassembly missing %q\n%s", check, got)
+		}
+	}
+}
+
+func TestDisassembleShowsFunctionHeaders(t *testing.T) {
+	chunk := &Chunk{Functions: []FunctionMeta{{Name: "_id", Arity: 1, Entry: 1, LocalCount: 2, Private: true}}}
+	chunk.Write(OP_TRUE)
+	chunk.WriteIndexed(OP_GET_LOCAL, 0)
+	chunk.Write(OP_RETURN)
+
+	want := "0000 OP_TRUE               \n" +
+		"def _id fn=0 arity=1 locals=2 private\n" +
+		"0001 OP_GET_LOCAL          0\n" +
+		"0003 OP_RETURN             \n"
+	if got := chunk.Disassemble(); got != want {
+		t.Fatalf("unexpected disassembly\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,318 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
+
+// The .brc file layout. Counts, lengths and indexes are unsigned varints, ints
+// are signed varints and floats are IEEE 754 bits in big-endian order.
+//
+//	magic      "\x7fBRC"
+//	version    uint16, big-endian
+//	flags      byte, flagDebug when the debug section is present
+//	constants  count, then each value as a tag byte followed by its payload
+//	functions  count, then name, arity byte, entry, locals and private byte
+//	globals    number of global slots
+//	code       length, then the instructions
+//	debug      source name, then the position runs as offset, line and column
+const (
+	FormatVersion = 2
+
+	flagDebug byte = 1 << 0
+)
+
+var magic = []byte{0x7f, 'B', 'R', 'C'}
+
+// Tags of the constant pool values
+const (
+	tagNil byte = iota
+	tagInt
+	tagFloat
+	tagBool
+	tagString
+	tagTuple
+)
+
+// ErrInvalidFormat is returned when decoding data that is not a valid .brc file
+var ErrInvalidFormat = errors.New("invalid bytecode file")
+
+// IsBinary reports whether data starts with the .brc magic header
+func IsBinary(data []byte) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == string(magic)
+}
+
+// MarshalBinary encodes the chunk in the .brc format. The debug section is only
+// written when the chunk has source positions or a source name.
+func (c *Chunk) MarshalBinary() ([]byte, error) {
+	out := append([]byte{}, magic...)
+	out = binary.BigEndian.AppendUint16(out, FormatVersion)
+
+	hasDebug := len(c.Positions) > 0 || c.SourceName != ""
+	var flags byte
+	if hasDebug {
+		flags |= flagDebug
+	}
+	out = append(out, flags)
+
+	out = binary.AppendUvarint(out, uint64(len(c.Constants)))
+	for _, v := range c.Constants {
+		var err error
+		if out, err = appendValue(out, v); err != nil {
+			return nil, err
+		}
+	}
+
+	out = binary.AppendUvarint(out, uint64(len(c.Functions)))
+	for _, fn := range c.Functions {
+		out = appendString(out, fn.Name)
+		out = append(out, fn.Arity)
+		out = binary.AppendUvarint(out, uint64(fn.Entry))
+		out = binary.AppendUvarint(out, uint64(fn.LocalCount))
+		out = appendBool(out, fn.Private)
+	}
+
+	out = binary.AppendUvarint(out, uint64(c.GlobalCount))
+
+	out = binary.AppendUvarint(out, uint64(len(c.Code)))
+	out = append(out, c.Code...)
+
+	if hasDebug {
+		out = appendString(out, c.SourceName)
+		out = binary.AppendUvarint(out, uint64(len(c.Positions)))
+		for _, run := range c.Positions {
+			out = binary.AppendUvarint(out, uint64(run.Offset))
+			out = binary.AppendUvarint(out, uint64(run.Position.Line))
+			out = binary.AppendUvarint(out, uint64(run.Position.Column))
+		}
+	}
+
+	return out, nil
+}
+
+func appendValue(out []byte, v value.Value) ([]byte, error) {
+	switch v.Kind {
+	case value.NilKind:
+		return append(out, tagNil), nil
+	case value.IntKind:
+		return binary.AppendVarint(append(out, tagInt), v.I), nil
+	case value.FloatKind:
+		return binary.BigEndian.AppendUint64(append(out, tagFloat), math.Float64bits(v.F)), nil
+	case value.BoolKind:
+		return appendBool(append(out, tagBool), v.B), nil
+	case value.StringKind:
+		return appendString(append(out, tagString), v.S), nil
+	case value.TupleKind:
+		out = binary.AppendUvarint(append(out, tagTuple), uint64(len(v.Items)))
+		for _, item := range v.Items {
+			var err error
+			if out, err = appendValue(out, item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("constant of kind %s cannot be encoded", v.Kind)
+	}
+}
+
+func appendString(out []byte, s string) []byte {
+	return append(binary.AppendUvarint(out, uint64(len(s))), s...)
+}
+
+func appendBool(out []byte, b bool) []byte {
+	if b {
+		return append(out, 1)
+	}
+	return append(out, 0)
+}
+
+// UnmarshalBinary replaces the chunk with the one encoded in data, which must
+// be in the .brc format written by MarshalBinary.
+func (c *Chunk) UnmarshalBinary(data []byte) error {
+	if !IsBinary(data) {
+		return fmt.Errorf("%w: missing magic header", ErrInvalidFormat)
+	}
+	d := &decoder{data: data, pos: len(magic)}
+
+	if version := d.uint16(); d.err == nil && version != FormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d, expected %d", ErrInvalidFormat, version, FormatVersion)
+	}
+	flags := d.byte()
+
+	decoded := Chunk{}
+	for range d.count() {
+		decoded.Constants = append(decoded.Constants, d.value(0))
+	}
+
+	for range d.count() {
+		decoded.Functions = append(decoded.Functions, FunctionMeta{
+			Name:       d.string(),
+			Arity:      d.byte(),
+			Entry:      d.int(),
+			LocalCount: d.int(),
+			Private:    d.bool(),
+		})
+	}
+
+	decoded.GlobalCount = d.int()
+
+	decoded.Code = append([]byte{}, d.bytes(d.count())...)
+
+	if flags&flagDebug != 0 {
+		decoded.SourceName = d.string()
+		for range d.count() {
+			decoded.Positions = append(decoded.Positions, PositionRun{
+				Offset:   d.int(),
+				Position: token.Position{Line: d.int(), Column: d.int()},
+			})
+		}
+	}
+
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("%d unexpected trailing bytes", len(d.data)-d.pos)
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	*c = decoded
+	return nil
+}
+
+// maxTupleDepth bounds the nesting of tuple constants accepted by the decoder
+const maxTupleDepth = 64
+
+// decoder reads the .brc fields in order, remembering the first error found.
+// Once it fails, every read returns a zero value.
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s at byte %d", ErrInvalidFormat, fmt.Sprintf(format, args...), d.pos)
+	}
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data)-d.pos {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool {
+	switch b := d.byte(); b {
+	case 0, 1:
+		return b == 1
+	default:
+		d.fail("invalid bool %d", b)
+		return false
+	}
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// int reads an unsigned varint that must fit in an int
+func (d *decoder) int() int {
+	v := d.uvarint()
+	if v > math.MaxInt32 {
+		d.fail("value %d out of range", v)
+		return 0
+	}
+	return int(v)
+}
+
+// count reads the number of items that follow. Every item takes at least one
+// byte, so a count larger than the remaining data is rejected up front.
+func (d *decoder) count() int {
+	n := d.int()
+	if n > len(d.data)-d.pos {
+		d.fail("count %d exceeds the remaining data", n)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.count()))
+}
+
+func (d *decoder) value(depth int) value.Value {
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return value.NewNil()
+	case tagInt:
+		if d.err != nil {
+			return value.Value{}
+		}
+		v, n := binary.Varint(d.data[d.pos:])
+		if n <= 0 {
+			d.fail("invalid varint")
+			return value.Value{}
+		}
+		d.pos += n
+		return value.NewInt(v)
+	case tagFloat:
+		if b := d.bytes(8); b != nil {
+			return value.NewFloat(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		}
+		return value.Value{}
+	case tagBool:
+		return value.NewBool(d.bool())
+	case tagString:
+		return value.NewString(d.string())
+	case tagTuple:
+		if depth >= maxTupleDepth {
+			d.fail("tuple nested deeper than %d", maxTupleDepth)
+			return value.Value{}
+		}
+		items := make([]value.Value, d.count())
+		for i := range items {
+			items[i] = d.value(depth + 1)
+		}
+		return value.NewTuple(items)
+	default:
+		d.fail("unknown constant tag %d", tag)
+		return value.Value{}
+	}
+}
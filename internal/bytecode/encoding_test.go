@@ -0,0 +1,115 @@
+package bytecode
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
+
+func sampleChunk() *Chunk {
+	chunk := &Chunk{SourceName: "main.brasa"}
+
+	chunk.SetPosition(token.Position{Line: 1, Column: 1})
+	jump := chunk.EmitJump(OP_JUMP_LONG)
+	chunk.SetPosition(token.Position{Line: 2, Column: 9})
+	chunk.WriteIndexed(OP_GET_LOCAL, 0)
+	chunk.Write(OP_RETURN)
+	chunk.PatchJump(jump)
+	chunk.SetPosition(token.Position{Line: 4, Column: 1})
+	chunk.WriteConst(value.NewInt(math.MinInt64))
+	chunk.WriteConst(value.NewFloat(-2.5))
+	chunk.WriteConst(value.NewString("brasa é \U0001F525"))
+	chunk.WriteConst(value.NewBool(true))
+	chunk.WriteConst(value.NewNil())
+	chunk.WriteConst(value.NewTuple([]value.Value{value.NewInt(1), value.NewTuple([]value.Value{value.NewBool(false), value.NewString("")})}))
+	chunk.WriteCall(0, 1)
+
+	chunk.Functions = []FunctionMeta{{Name: "identity", Arity: 1, Entry: 4, LocalCount: 300, Private: true}}
+	chunk.GlobalCount = 2
+	return chunk
+}
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	chunk := sampleChunk()
+
+	data, err := chunk.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !IsBinary(data) {
+		t.Fatal("expected encoded chunk to start with the magic header")
+	}
+
+	var decoded Chunk
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	chunk.SetPosition(token.Position{})
+	if !reflect.DeepEqual(&decoded, chunk) {
+		t.Fatalf("round trip mismatch\ngot:  %+v\nwant: %+v", decoded, *chunk)
+	}
+	if decoded.Disassemble() != chunk.Disassemble() {
+		t.Fatalf("disassembly differs after round trip")
+	}
+}
+
+func TestMarshalBinaryWithoutDebugInfo(t *testing.T) {
+	chunk := sampleChunk()
+	chunk.Positions, chunk.SourceName = nil, ""
+
+	data, err := chunk.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if data[6]&flagDebug != 0 {
+		t.Fatal("expected debug flag to be clear")
+	}
+
+	var decoded Chunk
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Positions != nil || decoded.SourceName != "" {
+		t.Fatalf("expected no debug info, got %+v", decoded.Positions)
+	}
+}
+
+func TestUnmarshalBinaryRejectsInvalidData(t *testing.T) {
+	data, err := sampleChunk().MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// every truncation must fail cleanly
+	for n := range len(data) {
+		var c Chunk
+		if err := c.UnmarshalBinary(data[:n]); !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("truncated to %d bytes: expected ErrInvalidFormat, got %v", n, err)
+		}
+	}
+
+	header := func(version int) []byte {
+		return []byte{0x7f, 'B', 'R', 'C', byte(version >> 8), byte(version)}
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"source text", []byte("x int = 1\n")},
+		{"trailing bytes", append(append([]byte{}, data...), 0)},
+		{"future version", append(header(FormatVersion+1), data[6:]...)},
+		{"unknown tag", append(header(FormatVersion), 0, 1, 99, 0, 0, 0)},
+		{"huge count", append(header(FormatVersion), 0, 0xff, 0xff, 0x03)},
+	}
+	for _, tt := range tests {
+		var c Chunk
+		if err := c.UnmarshalBinary(tt.data); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: expected ErrInvalidFormat, got %v", tt.name, err)
+		}
+	}
+}
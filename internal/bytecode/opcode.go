@@ -1,13 +1,18 @@
 package bytecode
 
+import "math"
+
 type OpCode byte
 
 const (
-	OP_CONST OpCode = iota
+	OP_CONST      OpCode = iota
+	OP_CONST_LONG        // OP_CONST with a 24-bit constant index
 	OP_ADD
 	OP_SUB
 	OP_MUL
 	OP_DIV
+	OP_MOD    // remainder of the division, with the sign of the dividend
+	OP_NEGATE // negate the number on top of the stack
 	OP_TRUE
 	OP_FALSE
 	OP_POP
@@ -20,25 +25,154 @@ const (
 	OP_LESS_EQUAL
 
 	OP_NOT
-	OP_AND
-	OP_OR
+
+	OP_TO_INT   // convert the top of the stack to int, truncating floats toward zero
+	OP_TO_FLOAT // convert the top of the stack to float
 
 	OP_JUMP
+	OP_JUMP_LONG // OP_JUMP with a 24-bit offset
 	OP_JUMP_IF_FALSE
+	OP_JUMP_IF_TRUE // jump when the top of the stack is true, leaving it there (used by ||)
+	OP_LOOP         // jump backwards by the given offset (used by loops)
 	OP_DEFINE_GLOBAL
+	OP_DEFINE_GLOBAL_LONG
 	OP_GET_GLOBAL
-	OP_DEFINE_LOCAL  // define a local variable (used in function bodies)
-	OP_GET_LOCAL     // get a local variable (used in function bodies)
-	OP_CALL          // call a function (used in function bodies)
+	OP_GET_GLOBAL_LONG
+	OP_SET_GLOBAL // assign to an already defined global variable
+	OP_SET_GLOBAL_LONG
+	OP_DEFINE_LOCAL // define a local variable (used in function bodies)
+	OP_DEFINE_LOCAL_LONG
+	OP_GET_LOCAL // get a local variable (used in function bodies)
+	OP_GET_LOCAL_LONG
+	OP_SET_LOCAL // assign to an already declared local variable (used in function bodies)
+	OP_SET_LOCAL_LONG
+	OP_CALL // call a function (used in function bodies)
+	OP_CALL_LONG
 	OP_BUILD_TUPLE   // build tuple from N top stack values
 	OP_RUNTIME_ERROR // raise a runtime error with a message (used in function bodies)
 	OP_RETURN        // return from a function (used in function bodies)
+
+	// Opcodes are appended from here on, so the numbers of the ones above stay
+	// valid in .brc files.
+
+	OP_UNPACK_TUPLE   // replace the tuple on top of the stack by its N items, checking its length
+	OP_GET_TUPLE_ITEM // replace the tuple on top of the stack by its item at index N
+	OP_BUILD_LIST     // build a new list from N top stack values
+	OP_GET_INDEX      // replace a collection and an index or key by the item stored there
+	OP_SET_INDEX      // store the value on top of the stack in a collection at an index or key, popping all three
+	OP_SLICE          // replace a list and two bounds by a new list with the items between them, nil bounds meaning the ends
+	OP_LEN            // replace a list, map or string by its length
+	OP_APPEND         // append the value on top of the stack to the list below it, leaving the list
+	OP_DUP2           // push a copy of the two values on top of the stack (used by compound index assignments)
+	OP_BUILD_MAP      // build a new map from N key and value pairs on top of the stack
+	OP_HAS            // replace a map and a key by whether the map has the key
+	OP_DELETE         // remove a key from the map below it, replacing both by nil
+	OP_KEYS           // replace a map by a new list of its keys in insertion order
+	OP_RANGE          // replace the low bound, high bound and step of a range by the state of OP_RANGE_NEXT, including the high bound when N is 1
+	OP_RANGE_NEXT     // push the next value of the range state on top of the stack, or jump forward when there is none
+	OP_ITER           // push the position 0 after the collection on top of the stack, making the state of OP_ITER_NEXT
+	OP_ITER_NEXT      // push the next item of the collection state on top of the stack, or jump forward when there is none
 )
 
+// longForms maps the opcodes taking a single byte index to their variant taking a 24-bit index
+var longForms = map[OpCode]OpCode{
+	OP_CONST:         OP_CONST_LONG,
+	OP_DEFINE_GLOBAL: OP_DEFINE_GLOBAL_LONG,
+	OP_GET_GLOBAL:    OP_GET_GLOBAL_LONG,
+	OP_SET_GLOBAL:    OP_SET_GLOBAL_LONG,
+	OP_DEFINE_LOCAL:  OP_DEFINE_LOCAL_LONG,
+	OP_GET_LOCAL:     OP_GET_LOCAL_LONG,
+	OP_SET_LOCAL:     OP_SET_LOCAL_LONG,
+	OP_CALL:          OP_CALL_LONG,
+}
+
+// Long returns the variant of op taking a 24-bit index, if op has one
+func (op OpCode) Long() (OpCode, bool) {
+	long, ok := longForms[op]
+	return long, ok
+}
+
+// opcodesByName maps the name of every opcode, as returned by String, to the opcode
+var opcodesByName = func() map[string]OpCode {
+	names := map[string]OpCode{}
+	for i := range math.MaxUint8 + 1 {
+		op := OpCode(i)
+		if name := op.String(); name != "OP_UNKNOWN" {
+			names[name] = op
+		}
+	}
+	return names
+}()
+
+// LookupOpCode returns the opcode with the given name, such as "OP_ADD"
+func LookupOpCode(name string) (OpCode, bool) {
+	op, ok := opcodesByName[name]
+	return op, ok
+}
+
+// OperandWidths returns the size in bytes of each operand that follows op in the code
+func (op OpCode) OperandWidths() []int {
+	switch op {
+	case OP_CONST, OP_DEFINE_GLOBAL, OP_GET_GLOBAL, OP_SET_GLOBAL, OP_DEFINE_LOCAL, OP_GET_LOCAL, OP_SET_LOCAL, OP_BUILD_TUPLE, OP_UNPACK_TUPLE, OP_GET_TUPLE_ITEM, OP_BUILD_LIST, OP_BUILD_MAP, OP_RANGE:
+		return []int{1}
+	case OP_CONST_LONG, OP_DEFINE_GLOBAL_LONG, OP_GET_GLOBAL_LONG, OP_SET_GLOBAL_LONG, OP_DEFINE_LOCAL_LONG, OP_GET_LOCAL_LONG, OP_SET_LOCAL_LONG, OP_JUMP_LONG:
+		return []int{3}
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE, OP_LOOP, OP_RANGE_NEXT, OP_ITER_NEXT:
+		return []int{2}
+	case OP_CALL:
+		return []int{1, 1} // function index, argument count
+	case OP_CALL_LONG:
+		return []int{3, 1}
+	default:
+		return nil
+	}
+}
+
+// stackEffect returns how many values op pops from the stack and how many it
+// pushes back, given its decoded operands. OP_RANGE_NEXT and OP_ITER_NEXT only
+// push their value when they do not jump.
+func (op OpCode) stackEffect(operands []int) (pops, pushes int) {
+	switch op {
+	case OP_CONST, OP_CONST_LONG, OP_TRUE, OP_FALSE, OP_GET_GLOBAL, OP_GET_GLOBAL_LONG, OP_GET_LOCAL, OP_GET_LOCAL_LONG, OP_RANGE_NEXT, OP_ITER_NEXT:
+		return 0, 1
+	case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_MOD, OP_EQUAL, OP_NOT_EQUAL, OP_GREATER, OP_LESS, OP_GREATER_EQUAL, OP_LESS_EQUAL:
+		return 2, 1
+	case OP_NEGATE, OP_NOT, OP_TO_INT, OP_TO_FLOAT, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE, OP_GET_TUPLE_ITEM, OP_LEN, OP_KEYS:
+		return 1, 1
+	case OP_GET_INDEX, OP_APPEND, OP_HAS, OP_DELETE:
+		return 2, 1
+	case OP_SLICE:
+		return 3, 1
+	case OP_RANGE:
+		return 3, 3
+	case OP_ITER:
+		return 1, 2
+	case OP_SET_INDEX:
+		return 3, 0
+	case OP_DUP2:
+		return 2, 4
+	case OP_POP, OP_DEFINE_GLOBAL, OP_DEFINE_GLOBAL_LONG, OP_SET_GLOBAL, OP_SET_GLOBAL_LONG,
+		OP_DEFINE_LOCAL, OP_DEFINE_LOCAL_LONG, OP_SET_LOCAL, OP_SET_LOCAL_LONG, OP_RETURN:
+		return 1, 0
+	case OP_CALL, OP_CALL_LONG:
+		return operands[1], 1
+	case OP_BUILD_TUPLE, OP_BUILD_LIST:
+		return operands[0], 1
+	case OP_BUILD_MAP:
+		return 2 * operands[0], 1
+	case OP_UNPACK_TUPLE:
+		return 1, operands[0]
+	default:
+		return 0, 0
+	}
+}
+
 func (op OpCode) String() string {
 	switch op {
 	case OP_CONST:
 		return "OP_CONST"
+	case OP_CONST_LONG:
+		return "OP_CONST_LONG"
 	case OP_ADD:
 		return "OP_ADD"
 	case OP_SUB:
@@ -47,6 +181,10 @@ func (op OpCode) String() string {
 		return "OP_MUL"
 	case OP_DIV:
 		return "OP_DIV"
+	case OP_MOD:
+		return "OP_MOD"
+	case OP_NEGATE:
+		return "OP_NEGATE"
 	case OP_TRUE:
 		return "OP_TRUE"
 	case OP_FALSE:
@@ -67,30 +205,88 @@ func (op OpCode) String() string {
 		return "OP_LESS_EQUAL"
 	case OP_NOT:
 		return "OP_NOT"
-	case OP_AND:
-		return "OP_AND"
-	case OP_OR:
-		return "OP_OR"
+	case OP_TO_INT:
+		return "OP_TO_INT"
+	case OP_TO_FLOAT:
+		return "OP_TO_FLOAT"
 	case OP_JUMP:
 		return "OP_JUMP"
+	case OP_JUMP_LONG:
+		return "OP_JUMP_LONG"
 	case OP_JUMP_IF_FALSE:
 		return "OP_JUMP_IF_FALSE"
+	case OP_JUMP_IF_TRUE:
+		return "OP_JUMP_IF_TRUE"
+	case OP_LOOP:
+		return "OP_LOOP"
 	case OP_DEFINE_GLOBAL:
 		return "OP_DEFINE_GLOBAL"
+	case OP_DEFINE_GLOBAL_LONG:
+		return "OP_DEFINE_GLOBAL_LONG"
 	case OP_GET_GLOBAL:
 		return "OP_GET_GLOBAL"
+	case OP_GET_GLOBAL_LONG:
+		return "OP_GET_GLOBAL_LONG"
+	case OP_SET_GLOBAL:
+		return "OP_SET_GLOBAL"
+	case OP_SET_GLOBAL_LONG:
+		return "OP_SET_GLOBAL_LONG"
 	case OP_DEFINE_LOCAL:
 		return "OP_DEFINE_LOCAL"
+	case OP_DEFINE_LOCAL_LONG:
+		return "OP_DEFINE_LOCAL_LONG"
 	case OP_GET_LOCAL:
 		return "OP_GET_LOCAL"
+	case OP_GET_LOCAL_LONG:
+		return "OP_GET_LOCAL_LONG"
+	case OP_SET_LOCAL:
+		return "OP_SET_LOCAL"
+	case OP_SET_LOCAL_LONG:
+		return "OP_SET_LOCAL_LONG"
 	case OP_CALL:
 		return "OP_CALL"
+	case OP_CALL_LONG:
+		return "OP_CALL_LONG"
 	case OP_BUILD_TUPLE:
 		return "OP_BUILD_TUPLE"
 	case OP_RUNTIME_ERROR:
 		return "OP_RUNTIME_ERROR"
 	case OP_RETURN:
 		return "OP_RETURN"
+	case OP_UNPACK_TUPLE:
+		return "OP_UNPACK_TUPLE"
+	case OP_GET_TUPLE_ITEM:
+		return "OP_GET_TUPLE_ITEM"
+	case OP_BUILD_LIST:
+		return "OP_BUILD_LIST"
+	case OP_GET_INDEX:
+		return "OP_GET_INDEX"
+	case OP_SET_INDEX:
+		return "OP_SET_INDEX"
+	case OP_SLICE:
+		return "OP_SLICE"
+	case OP_LEN:
+		return "OP_LEN"
+	case OP_APPEND:
+		return "OP_APPEND"
+	case OP_DUP2:
+		return "OP_DUP2"
+	case OP_BUILD_MAP:
+		return "OP_BUILD_MAP"
+	case OP_HAS:
+		return "OP_HAS"
+	case OP_DELETE:
+		return "OP_DELETE"
+	case OP_KEYS:
+		return "OP_KEYS"
+	case OP_RANGE:
+		return "OP_RANGE"
+	case OP_RANGE_NEXT:
+		return "OP_RANGE_NEXT"
+	case OP_ITER:
+		return "OP_ITER"
+	case OP_ITER_NEXT:
+		return "OP_ITER_NEXT"
 	default:
 		return "OP_UNKNOWN"
 	}
@@ -0,0 +1,255 @@
+package bytecode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidChunk is wrapped by every error returned by Verify
+var ErrInvalidChunk = errors.New("invalid bytecode")
+
+// mainCode names the top-level code of a chunk in verification errors
+const mainCode = "<main>"
+
+// VerifyError describes the first problem Verify found in a chunk
+type VerifyError struct {
+	Function string // Function where the problem was found, "<main>" for the top-level code or empty when unknown
+	Offset   int    // Offset of the offending instruction
+	Message  string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Function == "" {
+		return fmt.Sprintf("%v at offset %04d: %s", ErrInvalidChunk, e.Offset, e.Message)
+	}
+	return fmt.Sprintf("%v: %s at offset %04d: %s", ErrInvalidChunk, e.Function, e.Offset, e.Message)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return ErrInvalidChunk
+}
+
+// instruction is an opcode decoded by the verifier together with its operands
+type instruction struct {
+	op       OpCode
+	operands []int
+	next     int // offset of the instruction that follows in the code
+}
+
+// owner values of the offsets not claimed by any function
+const (
+	unreached = -2
+	mainOwner = -1
+)
+
+// verifier follows the control flow of each function, recording the function
+// owning each instruction and the stack depth on entry to it.
+type verifier struct {
+	chunk  *Chunk
+	instrs []*instruction // indexed by offset, nil between instruction boundaries
+	owner  []int          // index of the function owning each offset, or mainOwner
+	depth  []int          // values on the stack above the locals on entry to each offset
+}
+
+// Verify checks that the VM can run the chunk without reading outside of it:
+// every opcode is known and has all its operands, constant, global, local and
+// function indexes are in range, calls pass the arity of the callee, jumps land
+// on instruction boundaries of the same function and the stack depth on entry
+// to an instruction is the same on every path reaching it, without underflows.
+//
+// Verify returns a *VerifyError describing the first problem found.
+func (c *Chunk) Verify() error {
+	v := &verifier{
+		chunk:  c,
+		instrs: make([]*instruction, len(c.Code)),
+		owner:  make([]int, len(c.Code)),
+		depth:  make([]int, len(c.Code)),
+	}
+	for i := range v.owner {
+		v.owner[i] = unreached
+	}
+
+	if err := v.decode(); err != nil {
+		return err
+	}
+
+	for i, fn := range c.Functions {
+		switch {
+		case fn.LocalCount < int(fn.Arity) || fn.LocalCount > MaxIndex+1:
+			return v.errorf(i, fn.Entry, "%d locals cannot hold %d parameters", fn.LocalCount, fn.Arity)
+		case fn.Entry < 0 || fn.Entry >= len(c.Code) || v.instrs[fn.Entry] == nil:
+			return v.errorf(i, fn.Entry, "entry is not an instruction boundary")
+		}
+	}
+
+	if len(c.Code) > 0 {
+		if err := v.flow(mainOwner, 0); err != nil {
+			return err
+		}
+	}
+	for i, fn := range c.Functions {
+		if v.owner[fn.Entry] != unreached {
+			return v.errorf(i, fn.Entry, "entry is already part of %s", v.name(v.owner[fn.Entry]))
+		}
+		if err := v.flow(i, fn.Entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode splits the code in instructions, checking they are complete
+func (v *verifier) decode() error {
+	code := v.chunk.Code
+	for offset := 0; offset < len(code); {
+		op := OpCode(code[offset])
+		if op.String() == "OP_UNKNOWN" {
+			return &VerifyError{Offset: offset, Message: fmt.Sprintf("unknown opcode %d", op)}
+		}
+
+		in := &instruction{op: op, next: offset + 1}
+		for _, width := range op.OperandWidths() {
+			if in.next+width > len(code) {
+				return &VerifyError{Offset: offset, Message: fmt.Sprintf("%s is missing operands", op)}
+			}
+			in.operands = append(in.operands, readOperand(code[in.next:], width))
+			in.next += width
+		}
+
+		v.instrs[offset] = in
+		offset = in.next
+	}
+	return nil
+}
+
+// flow walks every instruction reachable from entry, claiming them for fn
+func (v *verifier) flow(fn, entry int) error {
+	localCount := 0
+	if fn != mainOwner {
+		localCount = v.chunk.Functions[fn].LocalCount
+	}
+
+	v.owner[entry], v.depth[entry] = fn, 0
+	work := []int{entry}
+	for len(work) > 0 {
+		offset := work[len(work)-1]
+		work = work[:len(work)-1]
+		in := v.instrs[offset]
+
+		if err := v.checkOperands(fn, offset, in, localCount); err != nil {
+			return err
+		}
+
+		pops, pushes := in.op.stackEffect(in.operands)
+		if v.depth[offset] < pops {
+			return v.errorf(fn, offset, "%s pops %d values from a stack of %d", in.op, pops, v.depth[offset])
+		}
+		depth := v.depth[offset] - pops + pushes
+
+		var targets []int
+		switch in.op {
+		case OP_RETURN, OP_RUNTIME_ERROR:
+		case OP_JUMP, OP_JUMP_LONG:
+			targets = []int{in.next + in.operands[0]}
+		case OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE:
+			targets = []int{in.next, in.next + in.operands[0]}
+		case OP_RANGE_NEXT, OP_ITER_NEXT:
+			// the jump is taken without pushing the next value
+			exit := in.next + in.operands[0]
+			reached, err := v.reach(fn, offset, exit, depth-pushes)
+			if err != nil {
+				return err
+			}
+			if reached {
+				work = append(work, exit)
+			}
+			targets = []int{in.next}
+		case OP_LOOP:
+			targets = []int{in.next - in.operands[0]}
+		default:
+			targets = []int{in.next}
+		}
+
+		for _, target := range targets {
+			reached, err := v.reach(fn, offset, target, depth)
+			if err != nil {
+				return err
+			}
+			if reached {
+				work = append(work, target)
+			}
+		}
+	}
+	return nil
+}
+
+// checkOperands checks that the indexes used by in are in range
+func (v *verifier) checkOperands(fn, offset int, in *instruction, localCount int) error {
+	c := v.chunk
+	switch in.op {
+	case OP_CONST, OP_CONST_LONG:
+		if in.operands[0] >= len(c.Constants) {
+			return v.errorf(fn, offset, "constant index %d out of range, the chunk has %d constants", in.operands[0], len(c.Constants))
+		}
+	case OP_DEFINE_GLOBAL, OP_DEFINE_GLOBAL_LONG, OP_GET_GLOBAL, OP_GET_GLOBAL_LONG, OP_SET_GLOBAL, OP_SET_GLOBAL_LONG:
+		if in.operands[0] >= c.GlobalCount {
+			return v.errorf(fn, offset, "global slot %d out of range, the chunk has %d globals", in.operands[0], c.GlobalCount)
+		}
+	case OP_DEFINE_LOCAL, OP_DEFINE_LOCAL_LONG, OP_GET_LOCAL, OP_GET_LOCAL_LONG, OP_SET_LOCAL, OP_SET_LOCAL_LONG:
+		if fn == mainOwner {
+			return v.errorf(fn, offset, "%s used outside a function", in.op)
+		}
+		if in.operands[0] >= localCount {
+			return v.errorf(fn, offset, "local slot %d out of range, the function has %d locals", in.operands[0], localCount)
+		}
+	case OP_CALL, OP_CALL_LONG:
+		fnIndex, argc := in.operands[0], in.operands[1]
+		if fnIndex >= len(c.Functions) {
+			return v.errorf(fn, offset, "function index %d out of range, the chunk has %d functions", fnIndex, len(c.Functions))
+		}
+		if callee := c.Functions[fnIndex]; argc != int(callee.Arity) {
+			return v.errorf(fn, offset, "call to %s passes %d args, expected %d", callee.Name, argc, callee.Arity)
+		}
+	}
+	return nil
+}
+
+// reach records that control flows from the instruction at offset to target
+// with depth values on the stack. It reports whether target was reached for
+// the first time and still has to be walked.
+func (v *verifier) reach(fn, offset, target, depth int) (bool, error) {
+	if target == len(v.chunk.Code) {
+		if fn != mainOwner {
+			return false, v.errorf(fn, offset, "control flows past the end of the code")
+		}
+		return false, nil
+	}
+	if target < 0 || target > len(v.chunk.Code) || v.instrs[target] == nil {
+		return false, v.errorf(fn, offset, "target %04d is not an instruction boundary", target)
+	}
+
+	switch v.owner[target] {
+	case unreached:
+		v.owner[target], v.depth[target] = fn, depth
+		return true, nil
+	case fn:
+		if v.depth[target] != depth {
+			return false, v.errorf(fn, offset, "stack depth %d at %04d, expected %d as on other paths", depth, target, v.depth[target])
+		}
+		return false, nil
+	default:
+		return false, v.errorf(fn, offset, "control flows into %s at %04d", v.name(v.owner[target]), target)
+	}
+}
+
+// name returns the name of the function at index fn, or mainCode
+func (v *verifier) name(fn int) string {
+	if fn == mainOwner {
+		return mainCode
+	}
+	return v.chunk.Functions[fn].Name
+}
+
+func (v *verifier) errorf(fn, offset int, format string, args ...any) *VerifyError {
+	return &VerifyError{Function: v.name(fn), Offset: offset, Message: fmt.Sprintf(format, args...)}
+}
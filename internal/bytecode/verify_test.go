@@ -0,0 +1,191 @@
+package bytecode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
+
+// verifiedChunk is a well formed chunk with a function returning from two
+// branches, a loop over a global and a call
+func verifiedChunk() *Chunk {
+	c := &Chunk{GlobalCount: 1}
+	jump := c.EmitJump(OP_JUMP_LONG)
+
+	entry := len(c.Code)
+	c.WriteIndexed(OP_GET_LOCAL, 0)
+	c.WriteConst(value.NewInt(0))
+	c.Write(OP_LESS)
+	negative := c.EmitJump(OP_JUMP_IF_FALSE)
+	c.Write(OP_POP)
+	c.WriteIndexed(OP_GET_LOCAL, 0)
+	c.Write(OP_NEGATE)
+	c.Write(OP_RETURN)
+	c.PatchJump(negative)
+	c.Write(OP_POP)
+	c.WriteIndexed(OP_GET_LOCAL, 0)
+	c.Write(OP_RETURN)
+	c.Functions = []FunctionMeta{{Name: "abs", Arity: 1, Entry: entry, LocalCount: 1}}
+
+	c.PatchJump(jump)
+	loop := len(c.Code)
+	c.WriteIndexed(OP_GET_GLOBAL, 0)
+	exit := c.EmitJump(OP_JUMP_IF_TRUE)
+	c.Write(OP_POP)
+	c.Write(OP_TRUE)
+	c.WriteIndexed(OP_SET_GLOBAL, 0)
+	c.EmitLoop(loop)
+	c.PatchJump(exit)
+	c.Write(OP_POP)
+	c.WriteConst(value.NewInt(-3))
+	c.WriteCall(0, 1)
+	return c
+}
+
+func TestVerifyAcceptsWellFormedChunks(t *testing.T) {
+	for _, c := range []*Chunk{{}, verifiedChunk()} {
+		if err := c.Verify(); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, c.Disassemble())
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunk  *Chunk
+		errMsg string
+	}{
+		{
+			name:   "unknown opcode",
+			chunk:  &Chunk{Code: []byte{byte(OP_TRUE), 0xff}},
+			errMsg: "offset 0001: unknown opcode 255",
+		},
+		{
+			name:   "missing operand",
+			chunk:  &Chunk{Code: []byte{byte(OP_CONST_LONG), 0, 0}},
+			errMsg: "OP_CONST_LONG is missing operands",
+		},
+		{
+			name:   "constant index",
+			chunk:  &Chunk{Code: []byte{byte(OP_CONST), 1}, Constants: []value.Value{value.NewNil()}},
+			errMsg: "<main> at offset 0000: constant index 1 out of range",
+		},
+		{
+			name:   "global slot",
+			chunk:  &Chunk{Code: []byte{byte(OP_GET_GLOBAL), 3}, GlobalCount: 3},
+			errMsg: "global slot 3 out of range",
+		},
+		{
+			name:   "local outside a function",
+			chunk:  &Chunk{Code: []byte{byte(OP_GET_LOCAL), 0}},
+			errMsg: "OP_GET_LOCAL used outside a function",
+		},
+		{
+			name:   "stack underflow",
+			chunk:  &Chunk{Code: []byte{byte(OP_TRUE), byte(OP_ADD)}},
+			errMsg: "OP_ADD pops 2 values from a stack of 1",
+		},
+		{
+			name:   "jump inside an instruction",
+			chunk:  &Chunk{Code: []byte{byte(OP_JUMP), 0, 1, byte(OP_CONST), 0}, Constants: []value.Value{value.NewNil()}},
+			errMsg: "target 0004 is not an instruction boundary",
+		},
+		{
+			name:   "jump past the end",
+			chunk:  &Chunk{Code: []byte{byte(OP_JUMP), 0, 9}},
+			errMsg: "target 0012 is not an instruction boundary",
+		},
+		{
+			name:   "loop before the start",
+			chunk:  &Chunk{Code: []byte{byte(OP_LOOP), 0, 4}},
+			errMsg: "target -001 is not an instruction boundary",
+		},
+		{
+			name: "unbalanced branches",
+			// the condition is popped on one path only
+			chunk:  &Chunk{Code: []byte{byte(OP_TRUE), byte(OP_JUMP_IF_FALSE), 0, 1, byte(OP_POP), byte(OP_TRUE)}},
+			errMsg: "stack depth 0 at 0005, expected 1 as on other paths",
+		},
+		{
+			name: "iteration exit",
+			// the exit of OP_ITER_NEXT is reached without the item pushed on the other path
+			chunk:  &Chunk{Code: []byte{byte(OP_TRUE), byte(OP_ITER), byte(OP_ITER_NEXT), 0, 0, byte(OP_POP)}},
+			errMsg: "stack depth 3 at 0005, expected 2 as on other paths",
+		},
+		{
+			name:   "growing loop",
+			chunk:  &Chunk{Code: []byte{byte(OP_TRUE), byte(OP_LOOP), 0, 4}},
+			errMsg: "stack depth 1 at 0000, expected 0",
+		},
+		{
+			name:   "unknown function",
+			chunk:  &Chunk{Code: []byte{byte(OP_CALL), 0, 0}},
+			errMsg: "function index 0 out of range",
+		},
+		{
+			name: "arity mismatch",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_JUMP), 0, 3, byte(OP_GET_LOCAL), 1, byte(OP_RETURN), byte(OP_CALL), 0, 0},
+				Functions: []FunctionMeta{{Name: "pair", Arity: 2, Entry: 3, LocalCount: 2}},
+			},
+			errMsg: "call to pair passes 0 args, expected 2",
+		},
+		{
+			name: "local slot",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_JUMP), 0, 3, byte(OP_GET_LOCAL), 1, byte(OP_RETURN)},
+				Functions: []FunctionMeta{{Name: "f", Arity: 1, Entry: 3, LocalCount: 1}},
+			},
+			errMsg: "f at offset 0003: local slot 1 out of range, the function has 1 locals",
+		},
+		{
+			name: "entry inside an instruction",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_JUMP), 0, 0},
+				Functions: []FunctionMeta{{Name: "f", Entry: 1}},
+			},
+			errMsg: "f at offset 0001: entry is not an instruction boundary",
+		},
+		{
+			name: "main runs into a function",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_TRUE), byte(OP_RETURN)},
+				Functions: []FunctionMeta{{Name: "f", Entry: 1}},
+			},
+			errMsg: "f at offset 0001: entry is already part of <main>",
+		},
+		{
+			name: "function runs past the end",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_JUMP), 0, 1, byte(OP_TRUE)},
+				Functions: []FunctionMeta{{Name: "f", Entry: 3}},
+			},
+			errMsg: "f at offset 0003: control flows past the end of the code",
+		},
+		{
+			name: "jump into another function",
+			chunk: &Chunk{
+				Code:      []byte{byte(OP_JUMP), 0, 3, byte(OP_JUMP), 0, 0, byte(OP_TRUE)},
+				Functions: []FunctionMeta{{Name: "f", Entry: 3}},
+			},
+			errMsg: "f at offset 0003: control flows into <main> at 0006",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.chunk.Verify()
+
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) || !errors.Is(err, ErrInvalidChunk) {
+				t.Fatalf("expected *VerifyError wrapping ErrInvalidChunk, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("expected %q in %q", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -1,45 +1,92 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/rafa-ribeiro/brasalang/internal/ast"
 	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
 	"github.com/rafa-ribeiro/brasalang/internal/token"
 	"github.com/rafa-ribeiro/brasalang/internal/value"
 )
 
-type functionMeta struct {
-	Name       string
-	Arity      byte
-	Entry      uint16
-	LocalCount byte
-	Private    bool
+// builtin is a function implemented directly by a VM opcode
+type builtin struct {
+	Op    bytecode.OpCode
+	Arity int
 }
 
+var builtins = map[string]builtin{
+	"int":    {Op: bytecode.OP_TO_INT, Arity: 1},
+	"float":  {Op: bytecode.OP_TO_FLOAT, Arity: 1},
+	"len":    {Op: bytecode.OP_LEN, Arity: 1},
+	"append": {Op: bytecode.OP_APPEND, Arity: 2},
+	"has":    {Op: bytecode.OP_HAS, Arity: 2},
+	"delete": {Op: bytecode.OP_DELETE, Arity: 2},
+	"keys":   {Op: bytecode.OP_KEYS, Arity: 1},
+}
+
+// loopContext tracks the jumps of the innermost loop being compiled
+type loopContext struct {
+	start      int   // offset of the condition check, target of continue
+	breakJumps []int // OP_JUMP operands to patch once the loop end is known
+}
+
+// variable is a declared name together with its storage slot
+type variable struct {
+	Slot int
+}
+
+// Compiler translates programs to bytecode. Globals and functions declared by a
+// program remain visible to the next programs compiled by the same Compiler,
+// which lets an interactive session build on its previous inputs.
 type Compiler struct {
-	globals   map[string]byte
-	functions map[string]byte
+	globals     map[string]variable
+	globalSlots int             // slots allocated to globals, including the loop slots
+	loopSlots   []int           // global slots of top-level loop variables, by loop depth
+	pending     map[string]bool // globals of the program being compiled not declared yet
+	functions   map[string]int
+	compiled    []*ast.FuncDeclStmt // functions of earlier programs, emitted again in every chunk
+	loops       []*loopContext
 }
 
 func New() *Compiler {
-	return &Compiler{globals: map[string]byte{}, functions: map[string]byte{}}
+	return &Compiler{globals: map[string]variable{}, functions: map[string]int{}}
 }
 
+// Compile returns the chunk for program. When it fails, the declarations of
+// program are discarded and the Compiler is left as it was before the call.
 func (c *Compiler) Compile(program *ast.Program) (*bytecode.Chunk, error) {
 	chunk := &bytecode.Chunk{}
+	var errs diagnostics.List
+
+	globals, functions := maps.Clone(c.globals), maps.Clone(c.functions)
+	globalSlots, loopSlots := c.globalSlots, len(c.loopSlots)
+	c.pending = map[string]bool{}
+	defer func() { c.pending = nil }()
 
 	metas := make([]bytecode.FunctionMeta, 0)
-	funcDecls := make([]*ast.FuncDeclStmt, 0)
+	funcDecls := slices.Clone(c.compiled)
 	mainStmts := make([]ast.Stmt, 0)
 
 	for _, stmt := range program.Statements {
 		if fn, ok := stmt.(*ast.FuncDeclStmt); ok {
 			if _, exists := c.functions[fn.Name.Lexeme]; exists {
-				return nil, fmt.Errorf("function %q already declared", fn.Name.Lexeme)
+				errs = append(errs, errorAt(fn, "function %q already declared", fn.Name.Lexeme))
+				continue
+			}
+			if _, exists := builtins[fn.Name.Lexeme]; exists {
+				errs = append(errs, errorAt(fn, "function %q shadows a built-in function", fn.Name.Lexeme))
+				continue
 			}
-			idx := byte(len(c.functions))
-			c.functions[fn.Name.Lexeme] = idx
+			if len(fn.Params) > bytecode.MaxCount {
+				errs = append(errs, errorAt(fn, "function %q has %d parameters, the limit is %d", fn.Name.Lexeme, len(fn.Params), bytecode.MaxCount))
+				continue
+			}
+			c.functions[fn.Name.Lexeme] = len(c.functions)
 			funcDecls = append(funcDecls, fn)
 			continue
 		}
@@ -47,101 +94,159 @@ func (c *Compiler) Compile(program *ast.Program) (*bytecode.Chunk, error) {
 	}
 
 	seenGlobals := map[string]bool{}
-	for _, stmt := range mainStmts {
-		decl, ok := stmt.(*ast.VarDeclStmt)
-		if !ok {
-			continue
+	declareGlobal := func(node ast.Node, name token.Token) {
+		if seenGlobals[name.Lexeme] {
+			errs = append(errs, errorAt(node, "variable %q already declared", name.Lexeme))
+			return
 		}
-		if seenGlobals[decl.Name.Lexeme] {
-			return nil, fmt.Errorf("variable %q already declared", decl.Name.Lexeme)
+		seenGlobals[name.Lexeme] = true
+		if _, exists := c.globals[name.Lexeme]; !exists {
+			c.global(name.Lexeme)
+			c.pending[name.Lexeme] = true
 		}
-		seenGlobals[decl.Name.Lexeme] = true
-		if _, exists := c.globals[decl.Name.Lexeme]; !exists {
-			c.globals[decl.Name.Lexeme] = byte(len(c.globals))
+	}
+	for _, stmt := range mainStmts {
+		switch decl := stmt.(type) {
+		case *ast.VarDeclStmt:
+			declareGlobal(decl, decl.Name)
+		case *ast.DestructureStmt:
+			for _, target := range decl.Targets {
+				if !target.Discard() {
+					declareGlobal(decl, target.Name)
+				}
+			}
 		}
 	}
 
-	jumpPos := chunk.EmitJump(bytecode.OP_JUMP)
+	// the functions may span more than an OP_JUMP can cover
+	jumpPos := chunk.EmitJump(bytecode.OP_JUMP_LONG)
 
 	for _, fn := range funcDecls {
 		meta, err := c.emitFunction(chunk, fn)
 		if err != nil {
-			return nil, err
+			errs = collect(errs, err)
+			continue
 		}
 		metas = append(metas, meta)
 	}
 
-	chunk.PatchJump(jumpPos)
+	if err := chunk.PatchJump(jumpPos); err != nil {
+		errs = append(errs, diagnostics.Errorf(diagnostics.CompileError, diagnostics.Span{}, "%v", err))
+	}
 
 	for i, stmt := range mainStmts {
 		if err := c.emitStmt(chunk, stmt, nil, nil); err != nil {
-			return nil, err
+			errs = collect(errs, err)
+			continue
 		}
 
 		if i < len(mainStmts)-1 {
 			if _, ok := stmt.(*ast.ExprStmt); ok {
+				chunk.SetPosition(stmt.Pos())
 				chunk.Write(bytecode.OP_POP)
 			}
 		}
 	}
 
+	if len(errs) > 0 {
+		c.globals, c.functions = globals, functions
+		c.globalSlots, c.loopSlots = globalSlots, c.loopSlots[:loopSlots]
+		return nil, errs
+	}
+
+	c.compiled = funcDecls
 	chunk.Functions = metas
+	chunk.GlobalCount = c.globalSlots
 	return chunk, nil
 }
 
+// global returns the global variable called name, allocating a slot for it when
+// it was not declared yet
+func (c *Compiler) global(name string) variable {
+	if global, exists := c.globals[name]; exists {
+		return global
+	}
+	global := variable{Slot: c.globalSlots}
+	c.globals[name] = global
+	c.globalSlots++
+	return global
+}
+
 func (c *Compiler) emitFunction(chunk *bytecode.Chunk, fn *ast.FuncDeclStmt) (bytecode.FunctionMeta, error) {
-	locals := map[string]byte{}
+	locals := map[string]variable{}
 	for i, p := range fn.Params {
-		locals[p.Name.Lexeme] = byte(i)
+		locals[p.Name.Lexeme] = variable{Slot: i}
 	}
 
-	entry := uint16(len(chunk.Code))
-	localCount := byte(len(locals))
+	defer chunk.SetPosition(chunk.SetPosition(fn.Pos()))
+
+	entry := len(chunk.Code)
+	var errs diagnostics.List
 	for i, stmt := range fn.Body.Statements {
 		if err := c.emitStmt(chunk, stmt, locals, fn); err != nil {
-			return bytecode.FunctionMeta{}, fmt.Errorf("in function %s: %w", fn.Name.Lexeme, err)
+			errs = collect(errs, err)
+			continue
 		}
 		if i < len(fn.Body.Statements)-1 {
 			if _, ok := stmt.(*ast.ExprStmt); ok {
+				chunk.SetPosition(stmt.Pos())
 				chunk.Write(bytecode.OP_POP)
 			}
 		}
 	}
 
+	if len(errs) > 0 {
+		for _, d := range errs {
+			d.WithRelated(diagnostics.TokenSpan(fn.Name), "in function %s", fn.Name.Lexeme)
+		}
+		return bytecode.FunctionMeta{}, errs
+	}
+
 	if len(fn.ReturnTypes) == 0 {
-		chunk.WriteConst(value.NewNil())
+		if err := chunk.WriteConst(value.NewNil()); err != nil {
+			return bytecode.FunctionMeta{}, errorAt(fn, "%v", err)
+		}
 		chunk.Write(bytecode.OP_RETURN)
 	} else {
 		chunk.Write(bytecode.OP_RUNTIME_ERROR)
 	}
 
+	// every local declared in the body gets a slot reserved when the function is called
+	localCount := len(locals)
+
 	return bytecode.FunctionMeta{Name: fn.Name.Lexeme, Arity: byte(len(fn.Params)), Entry: entry, LocalCount: localCount, Private: fn.Private}, nil
 }
 
-func (c *Compiler) emitStmt(chunk *bytecode.Chunk, stmt ast.Stmt, locals map[string]byte, fn *ast.FuncDeclStmt) error {
+func (c *Compiler) emitStmt(chunk *bytecode.Chunk, stmt ast.Stmt, locals map[string]variable, fn *ast.FuncDeclStmt) error {
+	// instructions are attributed to the innermost node being compiled
+	defer chunk.SetPosition(chunk.SetPosition(stmt.Pos()))
+
 	switch node := stmt.(type) {
 	case *ast.ExprStmt:
 		return c.emitExpr(chunk, node.Expression, locals)
 
 	case *ast.ReturnStmt:
 		if locals == nil {
-			return fmt.Errorf("return statement is only allowed inside functions")
+			return errorAt(node, "return statement is only allowed inside functions")
 		}
 
 		if len(fn.ReturnTypes) == 0 {
 			if len(node.Values) > 0 {
-				return fmt.Errorf("void function %q cannot return a value", fn.Name.Lexeme)
+				return errorAt(node, "void function %q cannot return a value", fn.Name.Lexeme)
+			}
+			if err := chunk.WriteConst(value.NewNil()); err != nil {
+				return errorAt(node, "%v", err)
 			}
-			chunk.WriteConst(value.NewNil())
 			chunk.Write(bytecode.OP_RETURN)
 			return nil
 		}
 
 		if len(node.Values) == 0 {
-			return fmt.Errorf("function %q return expects %d value(s)", fn.Name.Lexeme, len(fn.ReturnTypes))
+			return errorAt(node, "function %q return expects %d value(s)", fn.Name.Lexeme, len(fn.ReturnTypes))
 		}
-		if len(node.Values) != len(fn.ReturnTypes) {
-			return fmt.Errorf("function %q return expects %d value(s), got %d", fn.Name.Lexeme, len(fn.ReturnTypes), len(node.Values))
+		// a single value returned by a function with several return types is a tuple holding them all
+		if len(node.Values) != len(fn.ReturnTypes) && len(node.Values) != 1 {
+			return errorAt(node, "function %q return expects %d value(s), got %d", fn.Name.Lexeme, len(fn.ReturnTypes), len(node.Values))
 		}
 
 		for _, retExpr := range node.Values {
@@ -151,53 +256,400 @@ func (c *Compiler) emitStmt(chunk *bytecode.Chunk, stmt ast.Stmt, locals map[str
 		}
 
 		if len(node.Values) > 1 {
+			if len(node.Values) > bytecode.MaxCount {
+				return errorAt(node, "return of %d values exceeds the limit of %d", len(node.Values), bytecode.MaxCount)
+			}
 			chunk.Write(bytecode.OP_BUILD_TUPLE)
-			chunk.WriteByte(byte(len(node.Values)))
+			chunk.WriteUint8(byte(len(node.Values)))
 		}
 
 		chunk.Write(bytecode.OP_RETURN)
 		return nil
 
+	case *ast.BlockStmt:
+		return c.emitBlock(chunk, node.Statements, locals, fn)
+
+	case *ast.IfStmt:
+		if err := c.emitExpr(chunk, node.Condition, locals); err != nil {
+			return err
+		}
+
+		// OP_JUMP_IF_FALSE leaves the condition on the stack, so each branch pops it
+		thenJump := chunk.EmitJump(bytecode.OP_JUMP_IF_FALSE)
+		chunk.Write(bytecode.OP_POP)
+		if err := c.emitBlock(chunk, node.Then.Statements, locals, fn); err != nil {
+			return err
+		}
+		elseJump := chunk.EmitJump(bytecode.OP_JUMP)
+
+		if err := chunk.PatchJump(thenJump); err != nil {
+			return errorAt(node, "%v", err)
+		}
+		chunk.Write(bytecode.OP_POP)
+		if node.Else != nil {
+			if err := c.emitStmt(chunk, node.Else, locals, fn); err != nil {
+				return err
+			}
+		}
+		if err := chunk.PatchJump(elseJump); err != nil {
+			return errorAt(node, "%v", err)
+		}
+		return nil
+
+	case *ast.WhileStmt:
+		loop := &loopContext{start: len(chunk.Code)}
+
+		if err := c.emitExpr(chunk, node.Condition, locals); err != nil {
+			return err
+		}
+		exitJump := chunk.EmitJump(bytecode.OP_JUMP_IF_FALSE)
+		chunk.Write(bytecode.OP_POP)
+
+		c.loops = append(c.loops, loop)
+		err := c.emitBlock(chunk, node.Body.Statements, locals, fn)
+		c.loops = c.loops[:len(c.loops)-1]
+		if err != nil {
+			return err
+		}
+		if err := chunk.EmitLoop(loop.start); err != nil {
+			return errorAt(node, "%v", err)
+		}
+
+		if err := chunk.PatchJump(exitJump); err != nil {
+			return errorAt(node, "%v", err)
+		}
+		chunk.Write(bytecode.OP_POP)
+
+		// break jumps from inside the body, where the condition was already popped
+		for _, pos := range loop.breakJumps {
+			if err := chunk.PatchJump(pos); err != nil {
+				return errorAt(node, "%v", err)
+			}
+		}
+		return nil
+
+	case *ast.ForStmt:
+		return c.emitFor(chunk, node, locals, fn)
+
+	case *ast.BreakStmt:
+		if len(c.loops) == 0 {
+			return errorAt(node, "break statement is only allowed inside loops")
+		}
+		loop := c.loops[len(c.loops)-1]
+		loop.breakJumps = append(loop.breakJumps, chunk.EmitJump(bytecode.OP_JUMP))
+		return nil
+
+	case *ast.ContinueStmt:
+		if len(c.loops) == 0 {
+			return errorAt(node, "continue statement is only allowed inside loops")
+		}
+		if err := chunk.EmitLoop(c.loops[len(c.loops)-1].start); err != nil {
+			return errorAt(node, "%v", err)
+		}
+		return nil
+
+	case *ast.FuncDeclStmt:
+		return errorAt(node, "function %q must be declared at top level", node.Name.Lexeme)
+
 	case *ast.VarDeclStmt:
 
 		if locals == nil {
 			if err := c.emitExpr(chunk, node.Initializer, locals); err != nil {
 				return err
 			}
-			slot, exists := c.globals[node.Name.Lexeme]
-			if !exists {
-				slot = byte(len(c.globals))
-				c.globals[node.Name.Lexeme] = slot
+			global := c.global(node.Name.Lexeme)
+			delete(c.pending, node.Name.Lexeme)
+			if err := chunk.WriteIndexed(bytecode.OP_DEFINE_GLOBAL, global.Slot); err != nil {
+				return errorAt(node, "%v", err)
 			}
-			chunk.Write(bytecode.OP_DEFINE_GLOBAL)
-			chunk.WriteByte(slot)
 			return nil
 		}
 
 		if _, exists := locals[node.Name.Lexeme]; exists {
-			return fmt.Errorf("local variable %q already declared", node.Name.Lexeme)
+			return errorAt(node, "local variable %q already declared", node.Name.Lexeme)
 		}
 
 		if err := c.emitExpr(chunk, node.Initializer, locals); err != nil {
 			return err
 		}
 
-		slot := byte(len(locals))
-		locals[node.Name.Lexeme] = slot
-		chunk.Write(bytecode.OP_DEFINE_LOCAL)
-		chunk.WriteByte(slot)
+		slot := len(locals)
+		locals[node.Name.Lexeme] = variable{Slot: slot}
+		if err := chunk.WriteIndexed(bytecode.OP_DEFINE_LOCAL, slot); err != nil {
+			return errorAt(node, "%v", err)
+		}
 		return nil
+
+	case *ast.DestructureStmt:
+		return c.emitDestructure(chunk, node, locals)
+
+	case *ast.AssignStmt:
+		return c.emitAssign(chunk, node, locals)
+
+	case *ast.IndexAssignStmt:
+		return c.emitIndexAssign(chunk, node, locals)
+
 	default:
-		return fmt.Errorf("unsupported statement type %T", stmt)
+		return errorAt(stmt, "unsupported statement type %T", stmt)
+	}
+
+}
+
+// emitBlock emits the statements of a nested block. Unlike the last statement of
+// the program, no value produced inside a block is kept on the stack.
+func (c *Compiler) emitBlock(chunk *bytecode.Chunk, stmts []ast.Stmt, locals map[string]variable, fn *ast.FuncDeclStmt) error {
+	var errs diagnostics.List
+	for _, stmt := range stmts {
+		if err := c.emitStmt(chunk, stmt, locals, fn); err != nil {
+			errs = collect(errs, err)
+			continue
+		}
+		if _, ok := stmt.(*ast.ExprStmt); ok {
+			chunk.SetPosition(stmt.Pos())
+			chunk.Write(bytecode.OP_POP)
+		}
+	}
+	return errs.Err()
+}
+
+// emitFor compiles a for loop. The state of the iteration stays on the stack,
+// below the values used by the body, until the loop ends. The loop variable
+// gets a slot that is only bound to its name inside the body; afterwards the
+// slot is kept under a name no identifier can have, so that the next loop at
+// the same depth reuses it.
+func (c *Compiler) emitFor(chunk *bytecode.Chunk, node *ast.ForStmt, locals map[string]variable, fn *ast.FuncDeclStmt) error {
+	nextOp, stateSize := bytecode.OP_ITER_NEXT, 2
+	if r, ok := node.Iterable.(*ast.RangeExpr); ok {
+		for _, bound := range []ast.Expr{r.Low, r.High} {
+			if err := c.emitExpr(chunk, bound, locals); err != nil {
+				return err
+			}
+		}
+		if r.Step == nil {
+			if err := emitConst(chunk, r, value.NewInt(1)); err != nil {
+				return err
+			}
+		} else if err := c.emitExpr(chunk, r.Step, locals); err != nil {
+			return err
+		}
+		chunk.SetPosition(r.Pos())
+		chunk.Write(bytecode.OP_RANGE)
+		if r.Inclusive() {
+			chunk.WriteUint8(1)
+		} else {
+			chunk.WriteUint8(0)
+		}
+		nextOp, stateSize = bytecode.OP_RANGE_NEXT, 3
+	} else {
+		if err := c.emitExpr(chunk, node.Iterable, locals); err != nil {
+			return err
+		}
+		chunk.Write(bytecode.OP_ITER)
+	}
+	chunk.SetPosition(node.Pos())
+
+	name := node.Name.Lexeme
+	var slot int
+	defineOp := bytecode.OP_DEFINE_LOCAL
+	if locals == nil {
+		// globals declared later in the program are registered already, the
+		// loop variable hides them until the loop ends
+		shadowed, exists := c.globals[name]
+		if exists && !c.pending[name] {
+			return errorAt(node, "loop variable %q is already declared", name)
+		}
+		for len(c.loopSlots) <= len(c.loops) {
+			c.loopSlots = append(c.loopSlots, c.globalSlots)
+			c.globalSlots++
+		}
+		slot, defineOp = c.loopSlots[len(c.loops)], bytecode.OP_DEFINE_GLOBAL
+		c.globals[name] = variable{Slot: slot}
+		defer func() {
+			if exists {
+				c.globals[name] = shadowed
+			} else {
+				delete(c.globals, name)
+			}
+		}()
+	} else {
+		if _, exists := locals[name]; exists {
+			return errorAt(node, "loop variable %q is already declared", name)
+		}
+		hidden := fmt.Sprintf("for@%d", len(c.loops))
+		slot = len(locals)
+		if reserved, ok := locals[hidden]; ok {
+			slot = reserved.Slot
+			delete(locals, hidden)
+		}
+		locals[name] = variable{Slot: slot}
+		defer func() {
+			delete(locals, name)
+			locals[hidden] = variable{Slot: slot}
+		}()
+	}
+
+	loop := &loopContext{start: len(chunk.Code)}
+	exitJump := chunk.EmitJump(nextOp)
+	if err := chunk.WriteIndexed(defineOp, slot); err != nil {
+		return errorAt(node, "%v", err)
+	}
+
+	c.loops = append(c.loops, loop)
+	err := c.emitBlock(chunk, node.Body.Statements, locals, fn)
+	c.loops = c.loops[:len(c.loops)-1]
+	if err != nil {
+		return err
+	}
+	if err := chunk.EmitLoop(loop.start); err != nil {
+		return errorAt(node, "%v", err)
+	}
+
+	// break jumps from inside the body, where the state is still on the stack
+	if err := chunk.PatchJump(exitJump); err != nil {
+		return errorAt(node, "%v", err)
+	}
+	for _, pos := range loop.breakJumps {
+		if err := chunk.PatchJump(pos); err != nil {
+			return errorAt(node, "%v", err)
+		}
+	}
+	for range stateSize {
+		chunk.Write(bytecode.OP_POP)
+	}
+	return nil
+}
+
+// emitDestructure unpacks the tuple produced by the value of node and stores
+// its items, from the last one, in the variables declared by the targets
+func (c *Compiler) emitDestructure(chunk *bytecode.Chunk, node *ast.DestructureStmt, locals map[string]variable) error {
+	if len(node.Targets) > bytecode.MaxCount {
+		return errorAt(node, "destructuring into %d variables exceeds the limit of %d", len(node.Targets), bytecode.MaxCount)
 	}
 
+	if err := c.emitExpr(chunk, node.Value, locals); err != nil {
+		return err
+	}
+	chunk.Write(bytecode.OP_UNPACK_TUPLE)
+	chunk.WriteUint8(byte(len(node.Targets)))
+
+	slots := make([]int, len(node.Targets))
+	for i, target := range node.Targets {
+		name := target.Name.Lexeme
+		switch {
+		case target.Discard():
+		case locals == nil:
+			slots[i] = c.global(name).Slot
+			delete(c.pending, name)
+		default:
+			if _, exists := locals[name]; exists {
+				return errorAt(node, "local variable %q already declared", name)
+			}
+			slots[i] = len(locals)
+			locals[name] = variable{Slot: slots[i]}
+		}
+	}
+
+	defineOp := bytecode.OP_DEFINE_LOCAL
+	if locals == nil {
+		defineOp = bytecode.OP_DEFINE_GLOBAL
+	}
+	for i := len(node.Targets) - 1; i >= 0; i-- {
+		if node.Targets[i].Discard() {
+			chunk.Write(bytecode.OP_POP)
+			continue
+		}
+		if err := chunk.WriteIndexed(defineOp, slots[i]); err != nil {
+			return errorAt(node, "%v", err)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) emitAssign(chunk *bytecode.Chunk, node *ast.AssignStmt, locals map[string]variable) error {
+	name := node.Name.Lexeme
+
+	getOp, setOp := bytecode.OP_GET_GLOBAL, bytecode.OP_SET_GLOBAL
+	target, ok := c.globals[name]
+	if local, isLocal := locals[name]; isLocal {
+		getOp, setOp = bytecode.OP_GET_LOCAL, bytecode.OP_SET_LOCAL
+		target, ok = local, true
+	}
+	if !ok {
+		return errorAt(node, "cannot assign to undeclared variable %q", name)
+	}
+
+	if node.Operator.Type != token.EQUAL {
+		if err := chunk.WriteIndexed(getOp, target.Slot); err != nil {
+			return errorAt(node, "%v", err)
+		}
+	}
+
+	if err := c.emitExpr(chunk, node.Value, locals); err != nil {
+		return err
+	}
+
+	if node.Operator.Type != token.EQUAL {
+		op, err := mapCompoundOperator(node.Operator.Type)
+		if err != nil {
+			return errorAt(node, "%v", err)
+		}
+		chunk.SetPosition(node.Operator.Position)
+		chunk.Write(op)
+	}
+
+	if err := chunk.WriteIndexed(setOp, target.Slot); err != nil {
+		return errorAt(node, "%v", err)
+	}
+	return nil
+}
+
+// emitIndexAssign stores a value in a collection. Compound assignments keep a
+// copy of the collection and index on the stack to read the current item, so
+// both are evaluated only once.
+func (c *Compiler) emitIndexAssign(chunk *bytecode.Chunk, node *ast.IndexAssignStmt, locals map[string]variable) error {
+	if err := c.emitExpr(chunk, node.Target.Collection, locals); err != nil {
+		return err
+	}
+	if err := c.emitExpr(chunk, node.Target.Index, locals); err != nil {
+		return err
+	}
+
+	if node.Operator.Type != token.EQUAL {
+		chunk.Write(bytecode.OP_DUP2)
+		chunk.SetPosition(node.Target.Pos())
+		chunk.Write(bytecode.OP_GET_INDEX)
+	}
+
+	if err := c.emitExpr(chunk, node.Value, locals); err != nil {
+		return err
+	}
+
+	if node.Operator.Type != token.EQUAL {
+		op, err := mapCompoundOperator(node.Operator.Type)
+		if err != nil {
+			return errorAt(node, "%v", err)
+		}
+		chunk.SetPosition(node.Operator.Position)
+		chunk.Write(op)
+	}
+
+	chunk.SetPosition(node.Target.Pos())
+	chunk.Write(bytecode.OP_SET_INDEX)
+	return nil
 }
 
-func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[string]byte) error {
+func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[string]variable) error {
+	defer chunk.SetPosition(chunk.SetPosition(expr.Pos()))
+
 	switch node := expr.(type) {
 	case *ast.IntLiteral:
-		chunk.WriteConst(value.NewInt(node.Value))
-		return nil
+		return emitConst(chunk, node, value.NewInt(node.Value))
+
+	case *ast.FloatLiteral:
+		return emitConst(chunk, node, value.NewFloat(node.Value))
+
+	case *ast.StringLiteral:
+		return emitConst(chunk, node, value.NewString(node.Value))
 
 	case *ast.BoolLiteral:
 		if node.Value {
@@ -208,23 +660,24 @@ func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[str
 		return nil
 
 	case *ast.NilLiteral:
-		chunk.WriteConst(value.NewNil())
-		return nil
+		return emitConst(chunk, node, value.NewNil())
 
 	case *ast.Identifier:
 		if locals != nil {
-			if slot, ok := locals[node.Name]; ok {
-				chunk.Write(bytecode.OP_GET_LOCAL)
-				chunk.WriteByte(slot)
+			if local, ok := locals[node.Name]; ok {
+				if err := chunk.WriteIndexed(bytecode.OP_GET_LOCAL, local.Slot); err != nil {
+					return errorAt(node, "%v", err)
+				}
 				return nil
 			}
 		}
-		slot, ok := c.globals[node.Name]
+		global, ok := c.globals[node.Name]
 		if !ok {
-			return fmt.Errorf("identifier %q is not declared", node.Name)
+			return errorAt(node, "identifier %q is not declared", node.Name)
+		}
+		if err := chunk.WriteIndexed(bytecode.OP_GET_GLOBAL, global.Slot); err != nil {
+			return errorAt(node, "%v", err)
 		}
-		chunk.Write(bytecode.OP_GET_GLOBAL)
-		chunk.WriteByte(slot)
 		return nil
 
 	case *ast.CallExpr:
@@ -233,13 +686,98 @@ func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[str
 				return err
 			}
 		}
+		if b, ok := builtins[node.Callee.Lexeme]; ok {
+			if len(node.Arguments) != b.Arity {
+				return errorAt(node, "built-in function %q expects %d args, got %d", node.Callee.Lexeme, b.Arity, len(node.Arguments))
+			}
+			chunk.Write(b.Op)
+			return nil
+		}
 		fnIdx, ok := c.functions[node.Callee.Lexeme]
 		if !ok {
-			return fmt.Errorf("function %q is not declared", node.Callee.Lexeme)
+			return errorAt(node, "function %q is not declared", node.Callee.Lexeme)
+		}
+		if err := chunk.WriteCall(fnIdx, len(node.Arguments)); err != nil {
+			return errorAt(node, "%v", err)
 		}
-		chunk.Write(bytecode.OP_CALL)
-		chunk.WriteByte(fnIdx)
-		chunk.WriteByte(byte(len(node.Arguments)))
+		return nil
+
+	case *ast.TupleLiteral:
+		if len(node.Items) > bytecode.MaxCount {
+			return errorAt(node, "tuple of %d items exceeds the limit of %d", len(node.Items), bytecode.MaxCount)
+		}
+		for _, item := range node.Items {
+			if err := c.emitExpr(chunk, item, locals); err != nil {
+				return err
+			}
+		}
+		chunk.Write(bytecode.OP_BUILD_TUPLE)
+		chunk.WriteUint8(byte(len(node.Items)))
+		return nil
+
+	case *ast.ListLiteral:
+		if len(node.Items) > bytecode.MaxCount {
+			return errorAt(node, "list literal of %d items exceeds the limit of %d", len(node.Items), bytecode.MaxCount)
+		}
+		for _, item := range node.Items {
+			if err := c.emitExpr(chunk, item, locals); err != nil {
+				return err
+			}
+		}
+		chunk.Write(bytecode.OP_BUILD_LIST)
+		chunk.WriteUint8(byte(len(node.Items)))
+		return nil
+
+	case *ast.MapLiteral:
+		if len(node.Entries) > bytecode.MaxCount {
+			return errorAt(node, "map literal of %d entries exceeds the limit of %d", len(node.Entries), bytecode.MaxCount)
+		}
+		for _, entry := range node.Entries {
+			if err := c.emitExpr(chunk, entry.Key, locals); err != nil {
+				return err
+			}
+			if err := c.emitExpr(chunk, entry.Value, locals); err != nil {
+				return err
+			}
+		}
+		chunk.Write(bytecode.OP_BUILD_MAP)
+		chunk.WriteUint8(byte(len(node.Entries)))
+		return nil
+
+	case *ast.IndexExpr:
+		if err := c.emitExpr(chunk, node.Collection, locals); err != nil {
+			return err
+		}
+		if err := c.emitExpr(chunk, node.Index, locals); err != nil {
+			return err
+		}
+		chunk.Write(bytecode.OP_GET_INDEX)
+		return nil
+
+	case *ast.SliceExpr:
+		if err := c.emitExpr(chunk, node.Collection, locals); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expr{node.Low, node.High} {
+			if bound == nil {
+				if err := emitConst(chunk, node, value.NewNil()); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := c.emitExpr(chunk, bound, locals); err != nil {
+				return err
+			}
+		}
+		chunk.Write(bytecode.OP_SLICE)
+		return nil
+
+	case *ast.TupleIndexExpr:
+		if err := c.emitExpr(chunk, node.Tuple, locals); err != nil {
+			return err
+		}
+		chunk.Write(bytecode.OP_GET_TUPLE_ITEM)
+		chunk.WriteUint8(byte(node.Value))
 		return nil
 
 	case *ast.UnaryExpr:
@@ -250,17 +788,20 @@ func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[str
 			}
 			chunk.Write(bytecode.OP_NOT)
 		case token.MINUS:
-			chunk.WriteConst(value.NewInt(0))
 			if err := c.emitExpr(chunk, node.Right, locals); err != nil {
 				return err
 			}
-			chunk.Write(bytecode.OP_SUB)
+			chunk.Write(bytecode.OP_NEGATE)
 		default:
-			return fmt.Errorf("unsupported unary operator %s", node.Operator.Type)
+			return errorAt(node, "unsupported unary operator %s", node.Operator.Type)
 		}
 		return nil
 
 	case *ast.BinaryExpr:
+		if node.Operator.Type == token.AND_AND || node.Operator.Type == token.OR_OR {
+			return c.emitLogical(chunk, node, locals)
+		}
+
 		if err := c.emitExpr(chunk, node.Left, locals); err != nil {
 			return err
 		}
@@ -270,13 +811,78 @@ func (c *Compiler) emitExpr(chunk *bytecode.Chunk, expr ast.Expr, locals map[str
 
 		op, err := mapBinaryOperator(node.Operator.Type)
 		if err != nil {
-			return err
+			return errorAt(node, "%v", err)
 		}
 		chunk.Write(op)
 		return nil
 
 	default:
-		return fmt.Errorf("unsupported expression type %T", expr)
+		return errorAt(expr, "unsupported expression type %T", expr)
+	}
+}
+
+// emitLogical compiles && and || with short-circuit: when the left operand
+// already decides the result, it is kept on the stack and the right one is skipped.
+func (c *Compiler) emitLogical(chunk *bytecode.Chunk, node *ast.BinaryExpr, locals map[string]variable) error {
+	if err := c.emitExpr(chunk, node.Left, locals); err != nil {
+		return err
+	}
+
+	jumpOp := bytecode.OP_JUMP_IF_FALSE
+	if node.Operator.Type == token.OR_OR {
+		jumpOp = bytecode.OP_JUMP_IF_TRUE
+	}
+	endJump := chunk.EmitJump(jumpOp)
+	chunk.Write(bytecode.OP_POP)
+
+	if err := c.emitExpr(chunk, node.Right, locals); err != nil {
+		return err
+	}
+	if err := chunk.PatchJump(endJump); err != nil {
+		return errorAt(node, "%v", err)
+	}
+	return nil
+}
+
+// emitConst writes the instruction loading v, reporting at node when the constant pool is full
+func emitConst(chunk *bytecode.Chunk, node ast.Node, v value.Value) error {
+	if err := chunk.WriteConst(v); err != nil {
+		return errorAt(node, "%v", err)
+	}
+	return nil
+}
+
+func errorAt(node ast.Node, format string, args ...any) *diagnostics.Diagnostic {
+	return diagnostics.Errorf(diagnostics.CompileError, diagnostics.NodeSpan(node), format, args...)
+}
+
+// collect appends the diagnostics carried by err to errs
+func collect(errs diagnostics.List, err error) diagnostics.List {
+	var list diagnostics.List
+	if errors.As(err, &list) {
+		return append(errs, list...)
+	}
+	var d *diagnostics.Diagnostic
+	if errors.As(err, &d) {
+		return append(errs, d)
+	}
+	return append(errs, diagnostics.Errorf(diagnostics.CompileError, diagnostics.Span{}, "%v", err))
+}
+
+func mapCompoundOperator(op token.Type) (bytecode.OpCode, error) {
+	switch op {
+	case token.PLUS_EQUAL:
+		return bytecode.OP_ADD, nil
+	case token.MINUS_EQUAL:
+		return bytecode.OP_SUB, nil
+	case token.STAR_EQUAL:
+		return bytecode.OP_MUL, nil
+	case token.SLASH_EQUAL:
+		return bytecode.OP_DIV, nil
+	case token.PERCENT_EQUAL:
+		return bytecode.OP_MOD, nil
+	default:
+		return 0, fmt.Errorf("unsupported assignment operator %s", op)
 	}
 }
 
@@ -290,6 +896,8 @@ func mapBinaryOperator(op token.Type) (bytecode.OpCode, error) {
 		return bytecode.OP_MUL, nil
 	case token.SLASH:
 		return bytecode.OP_DIV, nil
+	case token.PERCENT:
+		return bytecode.OP_MOD, nil
 	case token.EQUAL_EQUAL:
 		return bytecode.OP_EQUAL, nil
 	case token.NOT_EQUAL:
@@ -302,10 +910,6 @@ func mapBinaryOperator(op token.Type) (bytecode.OpCode, error) {
 		return bytecode.OP_LESS, nil
 	case token.LESS_EQ:
 		return bytecode.OP_LESS_EQUAL, nil
-	case token.AND_AND:
-		return bytecode.OP_AND, nil
-	case token.OR_OR:
-		return bytecode.OP_OR, nil
 	default:
 		return 0, fmt.Errorf("unsupported binary operator %s", op)
 	}
@@ -1,9 +1,12 @@
 package compiler
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
 	"github.com/rafa-ribeiro/brasalang/internal/parser"
 	"github.com/rafa-ribeiro/brasalang/internal/value"
 	"github.com/rafa-ribeiro/brasalang/internal/vm"
@@ -118,6 +121,277 @@ func TestCompileAndRunTupleReturn(t *testing.T) {
 	}
 }
 
+func TestCompileAndRunTupleDestructuring(t *testing.T) {
+	src := `
+	def divmod(a int, b int) -> (int, int) {
+		return a / b, a % b
+	}
+	def describe(n int) -> (string, int, bool) {
+		return "n", n, n > 0
+	}
+	def digit_sum(n int) -> int {
+		sum int = 0
+		while n > 0 {
+			rest int, digit int = divmod(n, 10)
+			sum += digit
+			n = rest
+		}
+		return sum
+	}
+	q int, r int = divmod(17, 5)
+	_, n int, _ = describe(digit_sum(1234))
+	q * 100 + r * 10 + n
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 330 {
+		t.Fatalf("expected 330, got %v", result)
+	}
+}
+
+func TestDestructuringInNestedTopLevelBlock(t *testing.T) {
+	src := `
+	x int = 100
+	def dm(a int, b int) -> (int, int) {
+		return a / b, a % b
+	}
+	if true {
+		q int, r int = dm(7, 2)
+		x = x + q * 10 + r
+	}
+	x
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 131 {
+		t.Fatalf("expected 131, got %v", result)
+	}
+}
+
+func TestCompileAndRunTupleLiterals(t *testing.T) {
+	src := `
+	def swap(p (int, string)) -> (string, int) {
+		return p.1, p.0
+	}
+	def nested() -> ((int, int), bool) {
+		t ((int, int), bool) = ((1, 2), true)
+		return t
+	}
+	pair (int, string) = (3, "b")
+	same bool = swap(pair) == ("b", 3) && nested().0.1 == 2 && (1, 2) != (2, 1)
+	(swap(pair).1, same, nested(), "x, y")
+	`
+	result := compileAndRun(t, src)
+	if got, want := result.String(), `(3, true, ((1, 2), true), "x, y")`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestCompileAndRunLists(t *testing.T) {
+	src := `
+	def fill(xs list[int], n int) {
+		i int = 0
+		while i < n {
+			append(xs, i * i)
+			i += 1
+		}
+	}
+	def bump(xs list[int], i int) -> int {
+		xs[i] += 10
+		return xs[i]
+	}
+	squares list[int] = []
+	fill(squares, 5)
+	alias list[int] = squares
+	alias[0] = bump(squares, 1)
+	(squares, squares[1:4], squares[:2] == [11, 11], len(squares[3:]), [] == squares[5:])
+	`
+	result := compileAndRun(t, src)
+	if got, want := result.String(), "([11, 11, 4, 9, 16], [11, 4, 9], true, 2, true)"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestListRuntimeErrors(t *testing.T) {
+	tests := map[string]string{
+		"xs list[int] = [1, 2]\nxs[2]\n":              "2:3: index 2 out of range for list of 2 items",
+		"xs list[int] = [1, 2]\nxs[-1] = 0\n":         "2:3: index -1 out of range for list of 2 items",
+		"xs list[int] = [1, 2]\nxs[1:3]\n":            "2:3: slice bounds [1:3] out of range for list of 2 items",
+		"xs list[int] = [1, 2]\nn int = 2\nxs[n:1]\n": "3:3: slice bounds [2:1] out of range for list of 2 items",
+	}
+	for src, want := range tests {
+		chunk, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+		if err != nil {
+			t.Fatalf("compile error for %q: %v", src, err)
+		}
+		err = vm.New().Run(chunk)
+		if !errors.Is(err, vm.ErrIndexRange) || err.Error() != want {
+			t.Fatalf("expected %q for %q, got %v", want, src, err)
+		}
+	}
+}
+
+func TestCompileAndRunMaps(t *testing.T) {
+	src := `
+	def count(words list[string]) -> map[string]int {
+		counts map[string]int = {}
+		i int = 0
+		while i < len(words) {
+			if has(counts, words[i]) {
+				counts[words[i]] += 1
+			} else {
+				counts[words[i]] = 1
+			}
+			i += 1
+		}
+		return counts
+	}
+	counts map[string]int = count(["b", "a", "b", "c", "b"])
+	alias map[string]int = counts
+	delete(alias, "a")
+	delete(alias, "missing")
+	counts["a"] = 0
+	flags map[int]bool = {2: true, 1: false}
+	(counts, keys(counts), len(counts), counts == {"a": 0, "b": 3, "c": 1}, flags[1], {true: "y"})
+	`
+	result := compileAndRun(t, src)
+	if got, want := result.String(), `({"b": 3, "c": 1, "a": 0}, ["b", "c", "a"], 3, true, false, {true: "y"})`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestMapRuntimeErrors(t *testing.T) {
+	tests := map[string]string{
+		"m map[string]int = {\"a\": 1}\nm[\"b\"]\n": "2:2: key \"b\" not found in map",
+		"m map[int]int = {}\nm[7] += 1\n":           "2:2: key 7 not found in map",
+	}
+	for src, want := range tests {
+		chunk, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+		if err != nil {
+			t.Fatalf("compile error for %q: %v", src, err)
+		}
+		err = vm.New().Run(chunk)
+		if !errors.Is(err, vm.ErrKeyNotFound) || err.Error() != want {
+			t.Fatalf("expected %q for %q, got %v", want, src, err)
+		}
+	}
+}
+
+func TestCompileAndRunForLoops(t *testing.T) {
+	src := `
+	def sum_to(n int) -> int {
+		total int = 0
+		for i in 0..=n {
+			total += i
+		}
+		for i in 10..0 step -3 {
+			total += i * 1000
+		}
+		return total
+	}
+	out list[int] = []
+	for i in 0..10 step 2 {
+		if i == 4 {
+			continue
+		}
+		if i == 8 {
+			break
+		}
+		append(out, i)
+	}
+	for i in 0..2 {
+		for x in (7, 8) {
+			append(out, i * 10 + x)
+		}
+	}
+	ages map[string]int = {"a": 1, "b": 2}
+	names list[string] = []
+	for k in ages {
+		delete(ages, k)
+		ages[k + k] = 0
+		append(names, k)
+	}
+	for i in 3..3 {
+		append(out, -1)
+	}
+	for i in 9223372036854775805..=9223372036854775807 step 2 {
+		append(out, i - 9223372036854775800)
+	}
+	(sum_to(4), out, names, len(ages))
+	`
+	result := compileAndRun(t, src)
+	if got, want := result.String(), `(22010, [0, 2, 6, 7, 8, 17, 18, 5, 7], ["a", "b"], 2)`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestForLoopVariableSlotsAreReused(t *testing.T) {
+	src := `
+	def f() -> int {
+		sum int = 0
+		for i in 0..3 {
+			sum += i
+		}
+		for j in 0..3 {
+			for k in 0..3 {
+				sum += j * k
+			}
+		}
+		return sum
+	}
+	f()
+	`
+	chunk, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	// sum, i and j share the slot of the first loop level, k the second one
+	if got := chunk.Functions[0].LocalCount; got != 3 {
+		t.Fatalf("expected 3 locals, got %d", got)
+	}
+}
+
+func TestTopLevelLoopVariableShadowsLaterGlobal(t *testing.T) {
+	src := `
+	total int = 0
+	for i in 0..3 {
+		total += i
+	}
+	i int = 10
+	total * 100 + i
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 310 {
+		t.Fatalf("expected 310, got %v", result)
+	}
+}
+
+func TestTopLevelLoopSlotsDoNotLeakIntoGlobals(t *testing.T) {
+	c := New()
+	for _, src := range []string{"for i in 0..3 {\n}\n", "for j in 0..3 {\n}\n"} {
+		chunk, err := c.Compile(parser.NewFromSource(src).ParseProgram())
+		if err != nil {
+			t.Fatalf("compile error: %v", err)
+		}
+		if chunk.GlobalCount != 1 {
+			t.Fatalf("expected the loop slot to be reused, got %d globals", chunk.GlobalCount)
+		}
+	}
+	if len(c.globals) != 0 {
+		t.Fatalf("expected no globals to be left, got %v", c.globals)
+	}
+}
+
+func TestForLoopRuntimeErrors(t *testing.T) {
+	src := "step int = 0\nfor i in 0..3 step step {\n}\n"
+	chunk, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	err = vm.New().Run(chunk)
+	if want := "2:11: range step must not be zero"; !errors.Is(err, vm.ErrInvalidRange) || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+}
+
 func TestRuntimeErrorWhenFunctionWithReturnTypeOmitsReturn(t *testing.T) {
 	src := `
 	def bad(a int) -> int {
@@ -136,18 +410,467 @@ func TestRuntimeErrorWhenFunctionWithReturnTypeOmitsReturn(t *testing.T) {
 		t.Fatalf("compile error: %v", err)
 	}
 
+	err = vm.New().Run(chunk)
+
+	var runtimeErr *vm.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected runtime error, got %v", err)
+	}
+	if !strings.Contains(runtimeErr.Message, "function bad reached end without explicit return") || !errors.Is(err, vm.ErrMissingReturn) {
+		t.Fatalf("unexpected runtime error: %v", runtimeErr)
+	}
+	if len(runtimeErr.Trace) != 2 || runtimeErr.Trace[0].Function != "bad" || runtimeErr.Trace[1].Function != "<main>" {
+		t.Fatalf("unexpected stack trace: %+v", runtimeErr.Trace)
+	}
+}
+
+func TestCompileAndRunIfElseAtTopLevel(t *testing.T) {
+	src := `
+	a int = 5
+	if a > 10 {
+		size int = 1
+	} else if a > 3 {
+		size int = 2
+	} else {
+		size int = 3
+	}
+	size
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 2 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunIfInsideFunction(t *testing.T) {
+	src := `
+	def abs(x int) -> int {
+		if x < 0 {
+			return 0 - x
+		}
+		return x
+	}
+	abs(0 - 7) + abs(3)
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 10 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileIfWithoutElseLeavesStackBalanced(t *testing.T) {
+	src := `
+	if false {
+		1
+	}
+	if true {
+		2
+	}
+	42
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 42 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunWhileWithBreak(t *testing.T) {
+	src := `
+	while true {
+		if 1 < 2 {
+			break
+		}
+		1 / 0
+	}
+	while false {
+		1 / 0
+	}
+	7
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 7 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunWhileWithContinue(t *testing.T) {
+	// declaring a global again in a nested block stores into its slot
+	src := `
+	i int = 0
+	total int = 0
+	while i < 10 {
+		i int = i + 1
+		if i == 5 {
+			continue
+		}
+		total int = total + i
+	}
+	total
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 50 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileRejectsBreakOutsideLoop(t *testing.T) {
+	p := parser.NewFromSource("if true {\n  break\n}\n")
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	_, err := New().Compile(program)
+	if err == nil || !strings.Contains(err.Error(), "only allowed inside loops") {
+		t.Fatalf("expected compile error for break outside loop, got=%v", err)
+	}
+}
+
+func TestCompileAndRunGlobalReassignmentInLoop(t *testing.T) {
+	src := `
+	i int = 0
+	total int = 0
+	while i < 10 {
+		i += 1
+		if i == 5 {
+			continue
+		}
+		total += i
+	}
+	total
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 50 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunLocalCompoundAssignment(t *testing.T) {
+	src := `
+	def calc(x int) -> int {
+		acc int = x
+		acc *= 3
+		acc -= 1
+		acc /= 2
+		x = acc
+		return x
+	}
+	calc(5)
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 7 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileRejectsInvalidAssignments(t *testing.T) {
+	cases := map[string]string{
+		"missing = 1\n": "undeclared variable",
+	}
+
+	for src, want := range cases {
+		p := parser.NewFromSource(src)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("unexpected parse errors: %v", p.Errors())
+		}
+
+		_, err := New().Compile(program)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected compile error containing %q for %q, got=%v", want, src, err)
+		}
+	}
+}
+
+func TestCompileAndRunStringConcatenation(t *testing.T) {
+	src := `
+	greeting string = "olá"
+	greeting += ", " + "mundo\n"
+	greeting
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.StringKind || result.S != "olá, mundo\n" {
+		t.Fatalf("unexpected result: got=%q", result.S)
+	}
+}
+
+func TestCompileAndRunStringComparisons(t *testing.T) {
+	result := compileAndRun(t, `"abc" < "abd" && "x" == "x" && "x" != "y" && "b" >= "a"`+"\n")
+	if result.Kind != value.BoolKind || !result.B {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunIgnoresComments(t *testing.T) {
+	src := `
+	// counts down from three
+	n int = 3 /* start */
+	while n > 0 { // loop
+		n -= 1
+	}
+	/* result */
+	n
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.IntKind || result.I != 0 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunFloatArithmetic(t *testing.T) {
+	src := `
+	ratio float = 7 / 2.0
+	ratio *= 2
+	ratio - 0.5
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.FloatKind || result.F != 6.5 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunMixedNumericComparisons(t *testing.T) {
+	result := compileAndRun(t, "1 < 1.5 && 2.0 == 2 && 3 >= 2.5 && 0.1 != 0\n")
+	if result.Kind != value.BoolKind || !result.B {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileAndRunNumericConversions(t *testing.T) {
+	result := compileAndRun(t, "int(-7.9) + int(float(3) * 1.5)\n")
+	if result.Kind != value.IntKind || result.I != -3 {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+
+	result = compileAndRun(t, "float(1) / 4\n")
+	if result.Kind != value.FloatKind || result.String() != "0.25" {
+		t.Fatalf("unexpected result: got=%v", result)
+	}
+}
+
+func TestCompileReportsEveryErrorAsDiagnostics(t *testing.T) {
+	src := `
+	def f() {
+		return 1
+	}
+	a == 1
+	if true {
+		break
+	}
+	`
+	p := parser.NewFromSource(src)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	_, err := New().Compile(program)
+	var diags diagnostics.List
+	if !errors.As(err, &diags) {
+		t.Fatalf("expected diagnostics list, got %T: %v", err, err)
+	}
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d: %v", len(diags), diags)
+	}
+
+	if diags[0].Span.Start.Line != 3 || len(diags[0].Related) != 1 || !strings.Contains(diags[0].Related[0].Message, "in function f") {
+		t.Fatalf("unexpected diagnostic for void return: %+v", diags[0])
+	}
+	if diags[1].Span.Start.Line != 5 || diags[2].Span.Start.Line != 7 {
+		t.Fatalf("unexpected diagnostic positions: %v", diags)
+	}
+}
+
+func TestCompilerKeepsDeclarationsAcrossPrograms(t *testing.T) {
+	c := New()
 	machine := vm.New()
-	defer func() {
-		recovered := recover()
-		if recovered == nil {
-			t.Fatalf("expected runtime panic")
+
+	run := func(src string) (value.Value, error) {
+		chunk, err := c.Compile(parser.NewFromSource(src).ParseProgram())
+		if err != nil {
+			return value.Value{}, err
 		}
-		if !strings.Contains(recovered.(string), "without explicit return") {
-			t.Fatalf("unexpected panic: %v", recovered)
+		if err := chunk.Verify(); err != nil {
+			return value.Value{}, err
 		}
-	}()
+		if err := machine.Run(chunk); err != nil {
+			return value.Value{}, err
+		}
+		result, _ := machine.Result()
+		return result, nil
+	}
 
-	machine.Run(chunk)
+	if _, err := run("base int = 40\ndef add(a int, b int) -> int {\n\treturn a + b\n}\n"); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if _, err := run("def twice(n int) -> int {\n\treturn add(n, n)\n}\nbroken int = missing\n"); err == nil {
+		t.Fatal("expected compile error for undeclared name")
+	}
+
+	result, err := run("def twice(n int) -> int {\n\treturn add(n, n)\n}\nadd(base, twice(1))\n")
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if result.Kind != value.IntKind || result.I != 42 {
+		t.Fatalf("expected 42, got %v", result)
+	}
+}
+
+func TestRuntimeErrorPointsAtSource(t *testing.T) {
+	src := "def ratio(a int, b int) -> int {\n\treturn a / b\n}\nzero int = 0\nratio(10, zero)\n"
+
+	chunk, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	var runtimeErr *vm.RuntimeError
+	if err := vm.New().Run(chunk); !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected runtime error, got %v", err)
+	}
+	if runtimeErr.Error() != "2:11: division by zero" {
+		t.Fatalf("unexpected error: %v", runtimeErr)
+	}
+	if got := runtimeErr.StackTrace(); got != "  at ratio (2:11)\n  at <main> (5:1)\n" {
+		t.Fatalf("unexpected stack trace:\n%s", got)
+	}
+}
+
+func TestCompileAndRunBeyondSingleByteOperands(t *testing.T) {
+	var src strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&src, "def f%d(n int) -> int {\n", i)
+		if i == 299 {
+			for j := range 300 {
+				fmt.Fprintf(&src, "\tl%d int = n + %d\n", j, j)
+			}
+			src.WriteString("\treturn l299 + l0\n}\n")
+			continue
+		}
+		fmt.Fprintf(&src, "\treturn n + %d\n}\n", i)
+	}
+	for i := range 300 {
+		fmt.Fprintf(&src, "g%d int = %d\n", i, i)
+	}
+	src.WriteString("f299(g299) + f3(g1)\n")
+
+	chunk, err := New().Compile(parser.NewFromSource(src.String()).ParseProgram())
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if chunk.Functions[299].LocalCount != 301 {
+		t.Fatalf("expected 301 locals, got %d", chunk.Functions[299].LocalCount)
+	}
+
+	got := chunk.Disassemble()
+	for _, check := range []string{"OP_CONST_LONG", "OP_DEFINE_GLOBAL_LONG", "OP_GET_GLOBAL_LONG", "OP_DEFINE_LOCAL_LONG", "OP_GET_LOCAL_LONG", "OP_CALL_LONG"} {
+		if !strings.Contains(got, check) {
+			t.Fatalf("expected %s in disassembly", check)
+		}
+	}
+	if err := chunk.Verify(); err != nil {
+		t.Fatalf("compiled chunk rejected by the verifier: %v", err)
+	}
+
+	machine := vm.New()
+	if err := machine.Run(chunk); err != nil {
+		t.Fatalf("runtime error: %v", err)
+	}
+	// f299(299) = (299 + 299) + (299 + 0), f3(1) = 4
+	if result := machine.StackTop(); result.I != 901 {
+		t.Fatalf("expected 901, got %v", result)
+	}
+}
+
+func TestCompileRejectsTooManyParameters(t *testing.T) {
+	params := make([]string, 256)
+	for i := range params {
+		params[i] = fmt.Sprintf("p%d int", i)
+	}
+	src := "def wide(" + strings.Join(params, ", ") + ") {\n}\n"
+
+	_, err := New().Compile(parser.NewFromSource(src).ParseProgram())
+	if err == nil || !strings.Contains(err.Error(), "has 256 parameters, the limit is 255") {
+		t.Fatalf("expected parameter limit error, got %v", err)
+	}
+}
+
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	src := `
+	calls int = 0
+	def touch(result bool) -> bool {
+		calls += 1
+		return result
+	}
+	x int = 0
+	guarded bool = x != 0 && 10 / x > 1
+	fallback bool = x == 0 || 10 / x > 1
+	skipped bool = false && touch(true) || true || touch(false)
+	evaluated bool = true && touch(false) || touch(true)
+	!guarded && fallback && skipped && evaluated && calls == 2
+	`
+	result := compileAndRun(t, src)
+	if result.Kind != value.BoolKind || !result.B {
+		t.Fatalf("expected true, got %v", result)
+	}
+}
+
+func TestLogicalOperatorsCompileToConditionalJumps(t *testing.T) {
+	chunk, err := New().Compile(parser.NewFromSource("true && false || true\n").ParseProgram())
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	got := chunk.Disassemble()
+	if !strings.Contains(got, "OP_JUMP_IF_FALSE") || !strings.Contains(got, "OP_JUMP_IF_TRUE") {
+		t.Fatalf("expected conditional jumps in disassembly\n%s", got)
+	}
+}
+
+func TestModuloAndNegation(t *testing.T) {
+	result := compileAndRun(t, "r int = 17\nr %= 5\n7 + 10 % 4 * 2 - r + -(-3) + -7 % 3\n")
+	if result.Kind != value.IntKind || result.I != 11 {
+		t.Fatalf("expected 11, got %v", result)
+	}
+
+	result = compileAndRun(t, "-(0.0) == 0.0 && 7.5 % 2.0 == 1.5 && -(1.5) < 0.0\n")
+	if result.Kind != value.BoolKind || !result.B {
+		t.Fatalf("expected true, got %v", result)
+	}
+}
+
+func TestCheckedArithmeticRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"zero int = 0\n10 / zero\n", "2:4: division by zero"},
+		{"zero int = 0\n10 % zero\n", "2:4: modulo by zero"},
+		{"x int = 10\nx /= 0\n", "2:3: division by zero"},
+		{"big int = 9223372036854775807\nbig + 1\n", "2:5: integer overflow: 9223372036854775807 + 1"},
+		{"big int = 9223372036854775807\nbig * 2\n", "2:5: integer overflow: 9223372036854775807 * 2"},
+		{"min int = -9223372036854775807 - 1\nmin / -1\n", "2:5: integer overflow: -9223372036854775808 / -1"},
+		{"min int = -9223372036854775807 - 1\n-min\n", "2:1: integer overflow: -(-9223372036854775808)"},
+	}
+
+	for _, tt := range tests {
+		chunk, err := New().Compile(parser.NewFromSource(tt.src).ParseProgram())
+		if err != nil {
+			t.Fatalf("compile error for %q: %v", tt.src, err)
+		}
+
+		err = vm.New().Run(chunk)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%q: expected %q, got %v", tt.src, tt.err, err)
+		}
+	}
+}
+
+func TestEqualityOfBoolsAndNil(t *testing.T) {
+	result := compileAndRun(t, "!(true == false) && true != false && nil == nil && false == false\n")
+	if result.Kind != value.BoolKind || !result.B {
+		t.Fatalf("expected true, got %v", result)
+	}
 }
 
 func compileAndRun(t *testing.T, src string) value.Value {
@@ -164,9 +887,14 @@ func compileAndRun(t *testing.T, src string) value.Value {
 	if err != nil {
 		t.Fatalf("compile error: %v", err)
 	}
+	if err := chunk.Verify(); err != nil {
+		t.Fatalf("compiled chunk rejected by the verifier: %v", err)
+	}
 
 	machine := vm.New()
-	machine.Run(chunk)
+	if err := machine.Run(chunk); err != nil {
+		t.Fatalf("runtime error: %v", err)
+	}
 
 	return machine.StackTop()
 }
@@ -0,0 +1,242 @@
+// Package diagnostics defines the structured problems reported by every phase of
+// the Brasa toolchain (lexer, parser, semantic analyzer and compiler), so callers
+// can collect all of them from one run and render or serialize them as they need.
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code identifies the kind of problem. The prefix tells the phase that reported it.
+type Code string
+
+const (
+	// Lexer
+	UnexpectedCharacter Code = "LEX001"
+	UnterminatedString  Code = "LEX002"
+	InvalidEscape       Code = "LEX003"
+	UnterminatedComment Code = "LEX004"
+
+	// Parser
+	UnexpectedToken   Code = "PAR001"
+	ExpectedToken     Code = "PAR002"
+	InvalidLiteral    Code = "PAR003"
+	InvalidName       Code = "PAR004"
+	MissingTerminator Code = "PAR005"
+
+	// Semantic analysis
+	UndeclaredName     Code = "SEM001"
+	TypeMismatch       Code = "SEM002"
+	InvalidOperand     Code = "SEM003"
+	ArgumentCount      Code = "SEM004"
+	ReturnMismatch     Code = "SEM005"
+	Redeclaration      Code = "SEM006"
+	UnknownType        Code = "SEM007"
+	MisplacedStatement Code = "SEM008"
+	MissingReturn      Code = "SEM009"
+	ValueCount         Code = "SEM010"
+
+	// Compiler
+	CompileError Code = "CMP001"
+)
+
+// Span is the source range covered by a diagnostic. End is exclusive.
+type Span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+// Related points at another location that helps to explain a diagnostic,
+// such as a previous declaration.
+type Related struct {
+	Message string `json:"message"`
+	Span    Span   `json:"span"`
+}
+
+type Diagnostic struct {
+	Code     Code      `json:"code"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message"`
+	Span     Span      `json:"span"`
+	Related  []Related `json:"related,omitempty"`
+}
+
+// Errorf creates an error diagnostic covering span
+func Errorf(code Code, span Span, format string, args ...any) *Diagnostic {
+	return &Diagnostic{Code: code, Severity: Error, Message: fmt.Sprintf(format, args...), Span: span}
+}
+
+// WithRelated attaches a note pointing at span and returns the diagnostic
+func (d *Diagnostic) WithRelated(span Span, format string, args ...any) *Diagnostic {
+	d.Related = append(d.Related, Related{Message: fmt.Sprintf(format, args...), Span: span})
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s[%s]: %s", d.Span.Start.Line, d.Span.Start.Column, d.Severity, d.Code, d.Message)
+}
+
+// List is a set of diagnostics that can be returned as a single error
+type List []*Diagnostic
+
+func (l List) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns the list as an error, or nil when it is empty
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// HasErrors reports whether any diagnostic in the list has Error severity
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// At returns a span starting at pos and covering width columns
+func At(pos token.Position, width int) Span {
+	return Span{Start: pos, End: token.Position{Line: pos.Line, Column: pos.Column + max(width, 1)}}
+}
+
+// TokenSpan returns the span covered by the token lexeme, which may cross lines
+// for block comments.
+func TokenSpan(tok token.Token) Span {
+	switch tok.Type {
+	case token.NEWLINE, token.EOF:
+		return At(tok.Position, 1)
+	}
+
+	end := tok.Position
+	for _, ch := range tok.Lexeme {
+		if ch == '\n' {
+			end.Line++
+			end.Column = 1
+			continue
+		}
+		end.Column++
+	}
+	if end == tok.Position {
+		end.Column++
+	}
+	return Span{Start: tok.Position, End: end}
+}
+
+// NodeSpan returns the span covered by the node, from its first to its last token.
+func NodeSpan(node ast.Node) Span {
+	switch n := node.(type) {
+	case *ast.IntLiteral:
+		return TokenSpan(n.Token)
+	case *ast.FloatLiteral:
+		return TokenSpan(n.Token)
+	case *ast.BoolLiteral:
+		return TokenSpan(n.Token)
+	case *ast.StringLiteral:
+		return TokenSpan(n.Token)
+	case *ast.NilLiteral:
+		return TokenSpan(n.Token)
+	case *ast.Identifier:
+		return TokenSpan(n.Token)
+	case *ast.UnaryExpr:
+		return Span{Start: n.Operator.Position, End: NodeSpan(n.Right).End}
+	case *ast.RangeExpr:
+		end := n.High
+		if n.Step != nil {
+			end = n.Step
+		}
+		return Span{Start: NodeSpan(n.Low).Start, End: NodeSpan(end).End}
+	case *ast.BinaryExpr:
+		return Span{Start: NodeSpan(n.Left).Start, End: NodeSpan(n.Right).End}
+	case *ast.CallExpr:
+		return Span{Start: n.Callee.Position, End: TokenSpan(n.RParen).End}
+	case *ast.TupleLiteral:
+		return Span{Start: n.LParen.Position, End: TokenSpan(n.RParen).End}
+	case *ast.TupleIndexExpr:
+		return Span{Start: NodeSpan(n.Tuple).Start, End: TokenSpan(n.Index).End}
+	case *ast.ListLiteral:
+		return Span{Start: n.LBracket.Position, End: TokenSpan(n.RBracket).End}
+	case *ast.MapLiteral:
+		return Span{Start: n.LBrace.Position, End: TokenSpan(n.RBrace).End}
+	case *ast.IndexExpr:
+		return Span{Start: NodeSpan(n.Collection).Start, End: TokenSpan(n.RBracket).End}
+	case *ast.SliceExpr:
+		return Span{Start: NodeSpan(n.Collection).Start, End: TokenSpan(n.RBracket).End}
+	case *ast.NamedType:
+		return TokenSpan(n.Name)
+	case *ast.ListType:
+		return Span{Start: n.Name.Position, End: TokenSpan(n.RBracket).End}
+	case *ast.MapType:
+		return Span{Start: n.Name.Position, End: NodeSpan(n.Value).End}
+	case *ast.TupleType:
+		return Span{Start: n.LParen.Position, End: TokenSpan(n.RParen).End}
+	case *ast.ExprStmt:
+		return NodeSpan(n.Expression)
+	case *ast.VarDeclStmt:
+		return Span{Start: n.Name.Position, End: NodeSpan(n.Initializer).End}
+	case *ast.DestructureStmt:
+		return Span{Start: n.Targets[0].Name.Position, End: NodeSpan(n.Value).End}
+	case *ast.AssignStmt:
+		return Span{Start: n.Name.Position, End: NodeSpan(n.Value).End}
+	case *ast.IndexAssignStmt:
+		return Span{Start: NodeSpan(n.Target).Start, End: NodeSpan(n.Value).End}
+	case *ast.ReturnStmt:
+		if len(n.Values) == 0 {
+			return TokenSpan(n.Return)
+		}
+		return Span{Start: n.Return.Position, End: NodeSpan(n.Values[len(n.Values)-1]).End}
+	case *ast.BreakStmt:
+		return TokenSpan(n.Break)
+	case *ast.ContinueStmt:
+		return TokenSpan(n.Continue)
+	case *ast.IfStmt:
+		return Span{Start: n.If.Position, End: NodeSpan(n.Condition).End}
+	case *ast.WhileStmt:
+		return Span{Start: n.While.Position, End: NodeSpan(n.Condition).End}
+	case *ast.ForStmt:
+		return Span{Start: n.For.Position, End: NodeSpan(n.Iterable).End}
+	case *ast.FuncDeclStmt:
+		return Span{Start: n.DefToken.Position, End: TokenSpan(n.Name).End}
+	default:
+		return At(node.Pos(), 1)
+	}
+}
@@ -0,0 +1,37 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+func TestTokenSpanCoversLexeme(t *testing.T) {
+	tok := token.Token{Type: token.ILLEGAL, Lexeme: "/* a\nbc */", Position: token.Position{Line: 2, Column: 5}}
+
+	span := TokenSpan(tok)
+	if span.Start != tok.Position || span.End != (token.Position{Line: 3, Column: 6}) {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+}
+
+func TestDiagnosticRendersAndSerializes(t *testing.T) {
+	d := Errorf(TypeMismatch, At(token.Position{Line: 1, Column: 9}, 4), "cannot use %s value as %s", "bool", "int")
+	d.WithRelated(At(token.Position{Line: 1, Column: 1}, 1), "declared here")
+
+	if got, want := d.Error(), "1:9: error[SEM002]: cannot use bool value as int"; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+
+	out, err := json.Marshal(List{d})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	for _, want := range []string{`"code":"SEM002"`, `"severity":"error"`, `"related":[{"message":"declared here"`} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("json output missing %s: %s", want, out)
+		}
+	}
+}
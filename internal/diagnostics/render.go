@@ -0,0 +1,146 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// Printer renders diagnostics as annotated snippets of the source they refer to:
+//
+//	error[SEM002]: cannot use bool value as int in declaration of "a"
+//	  --> main.brasa:1:9
+//	   |
+//	 1 | a int = true
+//	   |         ^^^^
+type Printer struct {
+	Filename string
+	Source   string
+	Color    bool // emit ANSI escape codes
+
+	lines []string
+}
+
+func NewPrinter(filename, source string, color bool) *Printer {
+	return &Printer{Filename: filename, Source: source, Color: color, lines: strings.Split(source, "\n")}
+}
+
+// Print writes every diagnostic followed by a summary with the count per severity
+func (p *Printer) Print(w io.Writer, list List) {
+	for _, d := range list {
+		p.PrintDiagnostic(w, d)
+		fmt.Fprintln(w)
+	}
+	if len(list) > 0 {
+		fmt.Fprintln(w, p.paint(ansiBold, Summary(list)))
+	}
+}
+
+// PrintDiagnostic writes a single diagnostic with its source snippet and related notes
+func (p *Printer) PrintDiagnostic(w io.Writer, d *Diagnostic) {
+	color := severityColor(d.Severity)
+	fmt.Fprintf(w, "%s%s\n", p.paint(ansiBold+color, fmt.Sprintf("%s[%s]", d.Severity, d.Code)), p.paint(ansiBold, ": "+d.Message))
+
+	gutter := len(strconv.Itoa(d.Span.End.Line))
+	for _, r := range d.Related {
+		gutter = max(gutter, len(strconv.Itoa(r.Span.Start.Line)))
+	}
+	pad := strings.Repeat(" ", gutter)
+
+	fmt.Fprintf(w, "%s%s %s:%d:%d\n", pad, p.paint(ansiBlue, "-->"), p.Filename, d.Span.Start.Line, d.Span.Start.Column)
+	p.snippet(w, d.Span, gutter, '^', color)
+
+	for _, r := range d.Related {
+		fmt.Fprintf(w, "%s %s %s: %s (%s:%d:%d)\n", pad, p.paint(ansiBlue, "="), p.paint(ansiBold, "note"), r.Message, p.Filename, r.Span.Start.Line, r.Span.Start.Column)
+		p.snippet(w, r.Span, gutter, '-', ansiCyan)
+	}
+}
+
+// snippet writes the source lines covered by span with the covered columns underlined
+func (p *Printer) snippet(w io.Writer, span Span, gutter int, mark rune, color string) {
+	if span.Start.Line < 1 || span.Start.Line > len(p.lines) {
+		return
+	}
+
+	bar := p.paint(ansiBlue, "|")
+	fmt.Fprintf(w, "%s %s\n", strings.Repeat(" ", gutter), bar)
+
+	last := min(max(span.End.Line, span.Start.Line), len(p.lines))
+	for line := span.Start.Line; line <= last; line++ {
+		src := []rune(strings.TrimRight(p.lines[line-1], "\r"))
+
+		from, to := 1, len(src)+1
+		if line == span.Start.Line {
+			from = span.Start.Column
+		}
+		if line == span.End.Line {
+			to = span.End.Column
+		}
+		from = min(max(from, 1), len(src)+1)
+		to = max(to, from+1)
+
+		// keep tabs in the padding so the marks line up with the source above
+		var under strings.Builder
+		for i := 1; i < from; i++ {
+			if src[i-1] == '\t' {
+				under.WriteRune('\t')
+			} else {
+				under.WriteRune(' ')
+			}
+		}
+
+		num := p.paint(ansiBlue, fmt.Sprintf("%*d |", gutter, line))
+		fmt.Fprintf(w, "%s %s\n", num, string(src))
+		fmt.Fprintf(w, "%s %s %s%s\n", strings.Repeat(" ", gutter), bar, under.String(), p.paint(ansiBold+color, strings.Repeat(string(mark), to-from)))
+	}
+}
+
+func (p *Printer) paint(code, text string) string {
+	if !p.Color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// Summary describes how many diagnostics of each severity the list holds, e.g. "2 errors, 1 warning"
+func Summary(list List) string {
+	counts := map[Severity]int{}
+	for _, d := range list {
+		counts[d.Severity]++
+	}
+
+	var parts []string
+	for _, sev := range []Severity{Error, Warning, Note} {
+		n := counts[sev]
+		if n == 0 {
+			continue
+		}
+		noun := sev.String()
+		if n > 1 {
+			noun += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, noun))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Error:
+		return ansiRed
+	case Warning:
+		return ansiYellow
+	default:
+		return ansiCyan
+	}
+}
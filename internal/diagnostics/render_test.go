@@ -0,0 +1,49 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+func TestPrinterUnderlinesSpanAndSummarizes(t *testing.T) {
+	src := "def f() {\n}\n\ta int = true\n"
+	d := Errorf(TypeMismatch, Span{Start: token.Position{Line: 3, Column: 10}, End: token.Position{Line: 3, Column: 14}}, "cannot use bool value as int")
+	d.WithRelated(At(token.Position{Line: 1, Column: 5}, 1), "declared here")
+
+	var out strings.Builder
+	NewPrinter("main.brasa", src, false).Print(&out, List{d, Errorf(UndeclaredName, At(token.Position{Line: 2, Column: 1}, 1), "oops")})
+	got := out.String()
+
+	want := strings.Join([]string{
+		"error[SEM002]: cannot use bool value as int",
+		" --> main.brasa:3:10",
+		"  |",
+		"3 | \ta int = true",
+		"  | \t        ^^^^",
+		"  = note: declared here (main.brasa:1:5)",
+		"  |",
+		"1 | def f() {",
+		"  |     -",
+		"",
+	}, "\n")
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("unexpected rendering:\n%s\nwant prefix:\n%s", got, want)
+	}
+	if !strings.HasSuffix(got, "2 errors\n") {
+		t.Fatalf("missing summary:\n%s", got)
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Fatalf("unexpected ANSI codes without color:\n%s", got)
+	}
+}
+
+func TestPrinterColorsWhenEnabled(t *testing.T) {
+	var out strings.Builder
+	NewPrinter("x.brasa", "x\n", true).Print(&out, List{Errorf(UndeclaredName, At(token.Position{Line: 1, Column: 1}, 1), "oops")})
+
+	if !strings.Contains(out.String(), ansiRed) {
+		t.Fatalf("expected ANSI colors in output:\n%q", out.String())
+	}
+}
@@ -1,21 +1,29 @@
 //Package lexer implements a lexer for the Brasa programming language.
 // It takes an input string and produces a stream of tokens that can be consumed by the parser.
 // The lexer handles basic token types such as identifiers, integers, operators, and punctuation.
+// Comments are kept as trivia attached to the surrounding tokens.
 // It also keeps track of the current position in the source code for error reporting purposes.
 
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
 
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
 	"github.com/rafa-ribeiro/brasalang/internal/token"
 )
 
 type Lexer struct {
-	src  []rune
-	pos  int
-	line int
-	col  int
+	src   []rune
+	pos   int
+	line  int
+	col   int
+	prev  token.Type // Type of the last token returned, used to detect comment-only lines
+	diags diagnostics.List
 }
 
 // New creates a new Lexer instance with the given input string.
@@ -24,7 +32,25 @@ func New(input string) *Lexer {
 }
 
 // NextToken iterates through the input source mapping the characters to the corresponding token types and returns the next token in the stream.
+// Comments are not returned as tokens; they are attached to the nearest token as leading or trailing trivia.
 func (l *Lexer) NextToken() token.Token {
+	leading, illegal := l.scanLeadingTrivia()
+	if illegal != nil {
+		l.prev = illegal.Type
+		return *illegal
+	}
+
+	tok := l.scanToken()
+	tok.Leading = leading
+	if tok.Type != token.NEWLINE && tok.Type != token.EOF {
+		tok.Trailing = l.scanTrailingTrivia()
+	}
+
+	l.prev = tok.Type
+	return tok
+}
+
+func (l *Lexer) scanToken() token.Token {
 	l.skipWhitespace()
 	start := token.Position{Line: l.line, Column: l.col}
 
@@ -45,19 +71,50 @@ func (l *Lexer) NextToken() token.Token {
 		return token.Token{Type: token.LBRACE, Lexeme: "{", Position: start}
 	case '}':
 		return token.Token{Type: token.RBRACE, Lexeme: "}", Position: start}
+	case '[':
+		return token.Token{Type: token.LBRACKET, Lexeme: "[", Position: start}
+	case ']':
+		return token.Token{Type: token.RBRACKET, Lexeme: "]", Position: start}
 	case ',':
 		return token.Token{Type: token.COMMA, Lexeme: ",", Position: start}
+	case '.':
+		if l.match('.') {
+			if l.match('=') {
+				return token.Token{Type: token.DOT_DOT_EQUAL, Lexeme: "..=", Position: start}
+			}
+			return token.Token{Type: token.DOT_DOT, Lexeme: "..", Position: start}
+		}
+		return token.Token{Type: token.DOT, Lexeme: ".", Position: start}
+	case ':':
+		return token.Token{Type: token.COLON, Lexeme: ":", Position: start}
 	case '+':
+		if l.match('=') {
+			return token.Token{Type: token.PLUS_EQUAL, Lexeme: "+=", Position: start}
+		}
 		return token.Token{Type: token.PLUS, Lexeme: "+", Position: start}
 	case '-':
 		if l.match('>') {
 			return token.Token{Type: token.ARROW, Lexeme: "->", Position: start}
 		}
+		if l.match('=') {
+			return token.Token{Type: token.MINUS_EQUAL, Lexeme: "-=", Position: start}
+		}
 		return token.Token{Type: token.MINUS, Lexeme: "-", Position: start}
 	case '*':
+		if l.match('=') {
+			return token.Token{Type: token.STAR_EQUAL, Lexeme: "*=", Position: start}
+		}
 		return token.Token{Type: token.STAR, Lexeme: "*", Position: start}
 	case '/':
+		if l.match('=') {
+			return token.Token{Type: token.SLASH_EQUAL, Lexeme: "/=", Position: start}
+		}
 		return token.Token{Type: token.SLASH, Lexeme: "/", Position: start}
+	case '%':
+		if l.match('=') {
+			return token.Token{Type: token.PERCENT_EQUAL, Lexeme: "%=", Position: start}
+		}
+		return token.Token{Type: token.PERCENT, Lexeme: "%", Position: start}
 	case '!':
 		if l.match('=') {
 			return token.Token{Type: token.NOT_EQUAL, Lexeme: "!=", Position: start}
@@ -82,20 +139,18 @@ func (l *Lexer) NextToken() token.Token {
 		if l.match('&') {
 			return token.Token{Type: token.AND_AND, Lexeme: "&&", Position: start}
 		}
-		return token.Token{Type: token.ILLEGAL, Lexeme: "&", Position: start}
+		return l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: "&", Position: start}, diagnostics.UnexpectedCharacter, "unexpected character '&', did you mean '&&'?")
+	case '"':
+		return l.scanString(start)
 	case '|':
 		if l.match('|') {
 			return token.Token{Type: token.OR_OR, Lexeme: "||", Position: start}
 		}
-		return token.Token{Type: token.ILLEGAL, Lexeme: "|", Position: start}
+		return l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: "|", Position: start}, diagnostics.UnexpectedCharacter, "unexpected character '|', did you mean '||'?")
 	}
 
 	if unicode.IsDigit(ch) {
-		lex := []rune{ch}
-		for !l.isAtEnd() && unicode.IsDigit(l.peek()) {
-			lex = append(lex, l.advance())
-		}
-		return token.Token{Type: token.INT, Lexeme: string(lex), Position: start}
+		return l.scanNumber(ch, start)
 	}
 
 	if unicode.IsLetter(ch) || ch == '_' {
@@ -107,7 +162,12 @@ func (l *Lexer) NextToken() token.Token {
 		return token.Token{Type: token.LookupIdent(ident), Lexeme: ident, Position: start}
 	}
 
-	return token.Token{Type: token.ILLEGAL, Lexeme: string(ch), Position: start}
+	return l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: string(ch), Position: start}, diagnostics.UnexpectedCharacter, "unexpected character %q", ch)
+}
+
+// Diagnostics returns the problems found while producing ILLEGAL tokens so far
+func (l *Lexer) Diagnostics() diagnostics.List {
+	return l.diags
 }
 
 // Tokens returns a slice of all tokens in the input source by repeatedly calling NextToken until EOF is reached.
@@ -123,6 +183,217 @@ func (l *Lexer) Tokens() []token.Token {
 	return out
 }
 
+// scanNumber reads an integer or a float literal such as `1.5`, `2e10` or `1.5e-3`.
+// A dot only belongs to the number when a digit follows it. A number right after
+// a dot is a tuple index and is always an integer, so `t.0.1` is `t . 0 . 1`.
+func (l *Lexer) scanNumber(first rune, start token.Position) token.Token {
+	lex := []rune{first}
+	typ := token.INT
+	lex = append(lex, l.scanDigits()...)
+	if l.prev == token.DOT {
+		return token.Token{Type: typ, Lexeme: string(lex), Position: start}
+	}
+
+	if l.peek() == '.' && unicode.IsDigit(l.peekNext()) {
+		typ = token.FLOAT
+		lex = append(lex, l.advance())
+		lex = append(lex, l.scanDigits()...)
+	}
+
+	if l.peek() == 'e' || l.peek() == 'E' {
+		next := l.peekNext()
+		if unicode.IsDigit(next) || ((next == '+' || next == '-') && l.pos+2 < len(l.src) && unicode.IsDigit(l.src[l.pos+2])) {
+			typ = token.FLOAT
+			lex = append(lex, l.advance(), l.advance())
+			lex = append(lex, l.scanDigits()...)
+		}
+	}
+
+	return token.Token{Type: typ, Lexeme: string(lex), Position: start}
+}
+
+func (l *Lexer) scanDigits() []rune {
+	var digits []rune
+	for !l.isAtEnd() && unicode.IsDigit(l.peek()) {
+		digits = append(digits, l.advance())
+	}
+	return digits
+}
+
+// scanString reads a double-quoted string literal whose opening quote was already consumed.
+// The token lexeme keeps the quotes and escapes exactly as written; use Unquote to decode it.
+func (l *Lexer) scanString(start token.Position) token.Token {
+	lex := []rune{'"'}
+	for !l.isAtEnd() && l.peek() != '"' && l.peek() != '\n' {
+		ch := l.advance()
+		lex = append(lex, ch)
+		if ch == '\\' && !l.isAtEnd() && l.peek() != '\n' {
+			lex = append(lex, l.advance())
+		}
+	}
+
+	if !l.match('"') {
+		return l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: string(lex), Position: start}, diagnostics.UnterminatedString, "unterminated string literal")
+	}
+	lex = append(lex, '"')
+
+	if _, err := Unquote(string(lex)); err != nil {
+		return l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: string(lex), Position: start}, diagnostics.InvalidEscape, "invalid string literal: %v", err)
+	}
+	return token.Token{Type: token.STRING, Lexeme: string(lex), Position: start}
+}
+
+// Unquote decodes the lexeme of a STRING token into the string value it denotes.
+// Supported escapes are \n, \t, \", \\ and \u{...} with 1 to 6 hex digits.
+func Unquote(lexeme string) (string, error) {
+	if len(lexeme) < 2 || lexeme[0] != '"' || lexeme[len(lexeme)-1] != '"' {
+		return "", errors.New("string literal must be enclosed in double quotes")
+	}
+
+	src := []rune(lexeme[1 : len(lexeme)-1])
+	var out strings.Builder
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' {
+			out.WriteRune(src[i])
+			continue
+		}
+
+		i++
+		if i >= len(src) {
+			return "", errors.New("unterminated escape sequence")
+		}
+
+		switch src[i] {
+		case 'n':
+			out.WriteRune('\n')
+		case 't':
+			out.WriteRune('\t')
+		case '"':
+			out.WriteRune('"')
+		case '\\':
+			out.WriteRune('\\')
+		case 'u':
+			end := i + 1
+			for end < len(src) && src[end] != '}' {
+				end++
+			}
+			if i+1 >= len(src) || src[i+1] != '{' || end >= len(src) {
+				return "", errors.New(`unicode escape must have the form \u{...}`)
+			}
+
+			digits := string(src[i+2 : end])
+			code, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || len(digits) == 0 || len(digits) > 6 || code > unicode.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+				return "", fmt.Errorf("invalid unicode escape \\u{%s}", digits)
+			}
+			out.WriteRune(rune(code))
+			i = end
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", src[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// scanLeadingTrivia collects the comments before the next token. A line holding
+// only comments does not produce a NEWLINE token, so its comments end up attached
+// to the first token of the following line.
+func (l *Lexer) scanLeadingTrivia() ([]token.Trivia, *token.Token) {
+	var trivia []token.Trivia
+	atLineStart := l.prev == "" || l.prev == token.NEWLINE
+	lineHasComment := false
+
+	for {
+		l.skipWhitespace()
+
+		if l.atCommentStart() {
+			start := token.Position{Line: l.line, Column: l.col}
+			comment, ok := l.scanComment()
+			if !ok {
+				tok := l.illegal(token.Token{Type: token.ILLEGAL, Lexeme: comment.Text, Position: start}, diagnostics.UnterminatedComment, "unterminated block comment")
+				return nil, &tok
+			}
+			trivia = append(trivia, comment)
+			lineHasComment = true
+			continue
+		}
+
+		if atLineStart && lineHasComment && l.peek() == '\n' {
+			l.advance()
+			lineHasComment = false
+			continue
+		}
+
+		return trivia, nil
+	}
+}
+
+// scanTrailingTrivia collects the comments that follow a token on the same line.
+// An unterminated block comment is left in place so the next token reports it.
+func (l *Lexer) scanTrailingTrivia() []token.Trivia {
+	var trivia []token.Trivia
+
+	for {
+		l.skipWhitespace()
+		if !l.atCommentStart() {
+			return trivia
+		}
+
+		pos, line, col := l.pos, l.line, l.col
+		comment, ok := l.scanComment()
+		if !ok {
+			l.pos, l.line, l.col = pos, line, col
+			return trivia
+		}
+		trivia = append(trivia, comment)
+	}
+}
+
+func (l *Lexer) atCommentStart() bool {
+	return l.peek() == '/' && (l.peekNext() == '/' || l.peekNext() == '*')
+}
+
+// scanComment reads a `//` comment up to the end of the line or a `/* */` comment,
+// which may be nested. It reports false when a block comment is never closed.
+func (l *Lexer) scanComment() (token.Trivia, bool) {
+	start := token.Position{Line: l.line, Column: l.col}
+	from := l.pos
+	l.advance() // '/'
+
+	if l.advance() == '/' {
+		for !l.isAtEnd() && l.peek() != '\n' {
+			l.advance()
+		}
+		return token.Trivia{Kind: token.LINE_COMMENT, Text: string(l.src[from:l.pos]), Position: start}, true
+	}
+
+	depth := 1
+	for depth > 0 && !l.isAtEnd() {
+		switch {
+		case l.peek() == '/' && l.peekNext() == '*':
+			l.advance()
+			l.advance()
+			depth++
+		case l.peek() == '*' && l.peekNext() == '/':
+			l.advance()
+			l.advance()
+			depth--
+		default:
+			l.advance()
+		}
+	}
+
+	comment := token.Trivia{Kind: token.BLOCK_COMMENT, Text: string(l.src[from:l.pos]), Position: start}
+	return comment, depth == 0
+}
+
+// illegal records a diagnostic covering the ILLEGAL token and returns the token
+func (l *Lexer) illegal(tok token.Token, code diagnostics.Code, format string, args ...any) token.Token {
+	l.diags = append(l.diags, diagnostics.Errorf(code, diagnostics.TokenSpan(tok), format, args...))
+	return tok
+}
+
 func (l *Lexer) skipWhitespace() {
 	for !l.isAtEnd() {
 		ch := l.peek()
@@ -146,6 +417,13 @@ func (l *Lexer) peek() rune {
 	return l.src[l.pos]
 }
 
+func (l *Lexer) peekNext() rune {
+	if l.pos+1 >= len(l.src) {
+		return '\x00'
+	}
+	return l.src[l.pos+1]
+}
+
 func (l *Lexer) advance() rune {
 	ch := l.src[l.pos]
 	l.pos++
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/rafa-ribeiro/brasalang/internal/token"
@@ -55,3 +56,185 @@ func TestTokensArrowAndNil(t *testing.T) {
 		t.Fatalf("expected arrow and nil tokens, got: %#v", got)
 	}
 }
+
+func TestTokensListIndexAndSlice(t *testing.T) {
+	got := New("xs list[int] = [1]\nxs[0:2]").Tokens()
+
+	wantTypes := []token.Type{
+		token.IDENT, token.IDENT, token.LBRACKET, token.IDENT, token.RBRACKET, token.EQUAL,
+		token.LBRACKET, token.INT, token.RBRACKET, token.NEWLINE,
+		token.IDENT, token.LBRACKET, token.INT, token.COLON, token.INT, token.RBRACKET, token.EOF,
+	}
+	for i, want := range wantTypes {
+		if got[i].Type != want {
+			t.Fatalf("token[%d] = %s, want %s", i, got[i].Type, want)
+		}
+	}
+}
+
+func TestTokensForRanges(t *testing.T) {
+	got := New("for i in 0..n step 2 {\nfor j in 1..=2.5").Tokens()
+
+	wantTypes := []token.Type{
+		token.FOR, token.IDENT, token.IN, token.INT, token.DOT_DOT, token.IDENT, token.IDENT, token.INT, token.LBRACE, token.NEWLINE,
+		token.FOR, token.IDENT, token.IN, token.INT, token.DOT_DOT_EQUAL, token.FLOAT, token.EOF,
+	}
+	for i, want := range wantTypes {
+		if got[i].Type != want {
+			t.Fatalf("token[%d] = %s, want %s", i, got[i].Type, want)
+		}
+	}
+}
+
+func TestTokensCompoundAssignment(t *testing.T) {
+	l := New("x += 1 -= *= /= %= % -> =")
+	got := l.Tokens()
+
+	wantTypes := []token.Type{
+		token.IDENT,
+		token.PLUS_EQUAL,
+		token.INT,
+		token.MINUS_EQUAL,
+		token.STAR_EQUAL,
+		token.SLASH_EQUAL,
+		token.PERCENT_EQUAL,
+		token.PERCENT,
+		token.ARROW,
+		token.EQUAL,
+		token.EOF,
+	}
+
+	for i, want := range wantTypes {
+		if got[i].Type != want {
+			t.Fatalf("token[%d] = %s, want %s", i, got[i].Type, want)
+		}
+	}
+}
+
+func TestTokensStringLiteralKeepsSourceText(t *testing.T) {
+	l := New(`name string = "a\tb \"c\" \u{1F525}"`)
+	got := l.Tokens()
+
+	if got[3].Type != token.STRING {
+		t.Fatalf("token[3] = %s, want %s", got[3].Type, token.STRING)
+	}
+
+	decoded, err := Unquote(got[3].Lexeme)
+	if err != nil {
+		t.Fatalf("unexpected unquote error: %v", err)
+	}
+	if want := "a\tb \"c\" 🔥"; decoded != want {
+		t.Fatalf("decoded string = %q, want %q", decoded, want)
+	}
+}
+
+func TestTokensInvalidStringsAreIllegal(t *testing.T) {
+	for _, src := range []string{`"unterminated`, "\"line\nbreak\"", `"bad \q escape"`, `"\u{110000}"`, `"\u{}"`} {
+		tok := New(src).NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("expected ILLEGAL token for %q, got %s", src, tok.Type)
+		}
+	}
+}
+
+func TestCommentsAreAttachedAsTrivia(t *testing.T) {
+	src := "// header\n/* doc */\nx int = 1 // trailing\n/* a /* nested */ b */ y\n"
+	got := New(src).Tokens()
+
+	wantTypes := []token.Type{
+		token.IDENT,
+		token.IDENT,
+		token.EQUAL,
+		token.INT,
+		token.NEWLINE,
+		token.IDENT,
+		token.NEWLINE,
+		token.EOF,
+	}
+	if len(got) != len(wantTypes) {
+		t.Fatalf("token count mismatch: got=%d want=%d", len(got), len(wantTypes))
+	}
+	for i, want := range wantTypes {
+		if got[i].Type != want {
+			t.Fatalf("token[%d] = %s, want %s", i, got[i].Type, want)
+		}
+	}
+
+	if len(got[0].Leading) != 2 || got[0].Leading[0].Text != "// header" || got[0].Leading[1].Kind != token.BLOCK_COMMENT {
+		t.Fatalf("unexpected leading trivia on first token: %#v", got[0].Leading)
+	}
+	if len(got[3].Trailing) != 1 || got[3].Trailing[0].Text != "// trailing" {
+		t.Fatalf("unexpected trailing trivia on literal: %#v", got[3].Trailing)
+	}
+	if len(got[5].Leading) != 1 || got[5].Leading[0].Text != "/* a /* nested */ b */" {
+		t.Fatalf("unexpected nested block comment trivia: %#v", got[5].Leading)
+	}
+	if pos := got[5].Leading[0].Position; pos.Line != 4 || pos.Column != 1 {
+		t.Fatalf("unexpected trivia position: %+v", pos)
+	}
+}
+
+func TestUnterminatedBlockCommentIsIllegal(t *testing.T) {
+	got := New("x /* never closed").Tokens()
+
+	if got[0].Type != token.IDENT || got[1].Type != token.ILLEGAL {
+		t.Fatalf("expected IDENT followed by ILLEGAL, got %s %s", got[0].Type, got[1].Type)
+	}
+}
+
+func TestTokensNumbers(t *testing.T) {
+	got := New("42 1.5 2e10 1.5e-3 7E+2 3.x 4e").Tokens()
+
+	want := []struct {
+		typ    token.Type
+		lexeme string
+	}{
+		{token.INT, "42"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "2e10"},
+		{token.FLOAT, "1.5e-3"},
+		{token.FLOAT, "7E+2"},
+		{token.INT, "3"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "4"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Lexeme != w.lexeme {
+			t.Fatalf("token[%d] = %s %q, want %s %q", i, got[i].Type, got[i].Lexeme, w.typ, w.lexeme)
+		}
+	}
+}
+
+func TestTokensTupleIndexesAreInts(t *testing.T) {
+	got := New("t.0.1 t.1e2").Tokens()
+
+	want := []string{"t", ".", "0", ".", "1", "t", ".", "1", "e2"}
+	for i, lexeme := range want {
+		if got[i].Lexeme != lexeme {
+			t.Fatalf("token[%d] = %s %q, want %q", i, got[i].Type, got[i].Lexeme, lexeme)
+		}
+	}
+	if got[2].Type != token.INT || got[4].Type != token.INT {
+		t.Fatalf("expected INT indexes, got %s and %s", got[2].Type, got[4].Type)
+	}
+}
+
+func TestLexerReportsDiagnosticsForIllegalTokens(t *testing.T) {
+	l := New("a # b & c")
+	l.Tokens()
+
+	diags := l.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(diags), diags)
+	}
+	if diags[0].Span.Start.Column != 3 || !strings.Contains(diags[0].Message, "unexpected character '#'") {
+		t.Fatalf("unexpected first diagnostic: %v", diags[0])
+	}
+	if !strings.Contains(diags[1].Message, "did you mean '&&'") {
+		t.Fatalf("unexpected second diagnostic: %v", diags[1])
+	}
+}
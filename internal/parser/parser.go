@@ -1,11 +1,11 @@
 package parser
 
 import (
-	"fmt"
 	"regexp"
 	"strconv"
 
 	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
 	"github.com/rafa-ribeiro/brasalang/internal/lexer"
 	"github.com/rafa-ribeiro/brasalang/internal/token"
 )
@@ -15,7 +15,7 @@ var snakeCaseRegex = regexp.MustCompile(`^_?[a-z][a-z0-9_]*$`)
 type Parser struct {
 	tokens []token.Token
 	curr   int
-	errs   []error
+	errs   diagnostics.List
 }
 
 func New(tokens []token.Token) *Parser {
@@ -24,10 +24,13 @@ func New(tokens []token.Token) *Parser {
 
 func NewFromSource(src string) *Parser {
 	l := lexer.New(src)
-	return New(l.Tokens())
+	p := New(l.Tokens())
+	p.errs = append(p.errs, l.Diagnostics()...)
+	return p
 }
 
-func (p *Parser) Errors() []error {
+// Errors returns the diagnostics of the lexer (when parsing from source) and of the parser
+func (p *Parser) Errors() diagnostics.List {
 	return p.errs
 }
 
@@ -36,8 +39,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 	p.skipNewlines()
 
 	for !p.check(token.EOF) {
+		start := p.curr
 		stmt := p.parseStatement()
 		if stmt == nil {
+			p.skipFailedToken(start)
 			p.synchronize()
 			p.skipNewlines()
 			continue
@@ -55,14 +60,28 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Stmt {
 	switch {
-	case p.check(token.LBRACE):
+	case p.check(token.LBRACE) && !p.isMapLiteralStart():
 		return p.parseBlockStatement()
 	case p.check(token.DEF):
 		return p.parseFuncDeclStatement()
+	case p.check(token.IF):
+		return p.parseIfStatement()
+	case p.check(token.WHILE):
+		return p.parseWhileStatement()
+	case p.check(token.FOR):
+		return p.parseForStatement()
+	case p.check(token.BREAK):
+		return &ast.BreakStmt{Break: p.advance()}
+	case p.check(token.CONTINUE):
+		return &ast.ContinueStmt{Continue: p.advance()}
 	case p.check(token.RETURN):
 		return p.parseReturnStatement()
+	case p.isDestructureStart():
+		return p.parseDestructureStatement()
 	case p.isVarDeclStart():
 		return p.parseVarDeclStatement()
+	case p.isAssignStart():
+		return p.parseAssignStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -77,8 +96,8 @@ func (p *Parser) parseFuncDeclStatement() ast.Stmt {
 	}
 
 	if !snakeCaseRegex.MatchString(nameTok.Lexeme) {
-		p.errs = append(p.errs, fmt.Errorf("function %q must be snake_case at %d:%d", nameTok.Lexeme, nameTok.Position.Line, nameTok.Position.Column))
-		return nil
+		// keep parsing the declaration so its body does not produce follow-up errors
+		p.errorAt(nameTok, diagnostics.InvalidName, "function %q must be snake_case", nameTok.Lexeme)
 	}
 
 	if _, ok := p.expect(token.LPAREN, "expected '(' after function name"); !ok {
@@ -91,8 +110,8 @@ func (p *Parser) parseFuncDeclStatement() ast.Stmt {
 		if !ok {
 			return nil
 		}
-		paramType, ok := p.expect(token.IDENT, "expected parameter type")
-		if !ok {
+		paramType := p.parseType("expected parameter type")
+		if paramType == nil {
 			return nil
 		}
 		params = append(params, ast.Param{Name: paramName, Type: paramType})
@@ -108,34 +127,18 @@ func (p *Parser) parseFuncDeclStatement() ast.Stmt {
 		return nil
 	}
 
-	returnTypes := make([]token.Token, 0)
+	// a tuple return type lists the values returned by the function
+	returnTypes := make([]ast.TypeExpr, 0)
 	if p.check(token.ARROW) {
 		p.advance()
 
-		if p.check(token.LPAREN) {
-			p.advance()
-			for {
-				typ, ok := p.expect(token.IDENT, "expected return type")
-				if !ok {
-					return nil
-				}
-				returnTypes = append(returnTypes, typ)
-
-				if p.check(token.COMMA) {
-					p.advance()
-					continue
-				}
-				break
-			}
-
-			if _, ok := p.expect(token.RPAREN, "expected ')' after return types"); !ok {
-				return nil
-			}
+		typ := p.parseType("expected return type")
+		if typ == nil {
+			return nil
+		}
+		if tuple, ok := typ.(*ast.TupleType); ok {
+			returnTypes = append(returnTypes, tuple.Items...)
 		} else {
-			typ, ok := p.expect(token.IDENT, "expected return type")
-			if !ok {
-				return nil
-			}
 			returnTypes = append(returnTypes, typ)
 		}
 	}
@@ -176,8 +179,243 @@ func (p *Parser) parseReturnStatement() ast.Stmt {
 
 }
 
+func (p *Parser) parseIfStatement() ast.Stmt {
+	ifTok, _ := p.expect(token.IF, "expected 'if'")
+
+	condition := p.parseExpression()
+	if condition == nil {
+		return nil
+	}
+
+	if !p.check(token.LBRACE) {
+		p.expect(token.LBRACE, "expected '{' after if condition")
+		return nil
+	}
+	thenStmt := p.parseBlockStatement()
+	if thenStmt == nil {
+		return nil
+	}
+	stmt := &ast.IfStmt{If: ifTok, Condition: condition, Then: thenStmt.(*ast.BlockStmt)}
+
+	// else must be on the same line as the closing brace of the previous branch
+	if !p.check(token.ELSE) {
+		return stmt
+	}
+	p.advance()
+
+	if p.check(token.IF) {
+		elseIf := p.parseIfStatement()
+		if elseIf == nil {
+			return nil
+		}
+		stmt.Else = elseIf
+		return stmt
+	}
+
+	if !p.check(token.LBRACE) {
+		p.expect(token.LBRACE, "expected '{' or 'if' after 'else'")
+		return nil
+	}
+	elseStmt := p.parseBlockStatement()
+	if elseStmt == nil {
+		return nil
+	}
+	stmt.Else = elseStmt
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Stmt {
+	whileTok, _ := p.expect(token.WHILE, "expected 'while'")
+
+	condition := p.parseExpression()
+	if condition == nil {
+		return nil
+	}
+
+	if !p.check(token.LBRACE) {
+		p.expect(token.LBRACE, "expected '{' after while condition")
+		return nil
+	}
+	body := p.parseBlockStatement()
+	if body == nil {
+		return nil
+	}
+
+	return &ast.WhileStmt{While: whileTok, Condition: condition, Body: body.(*ast.BlockStmt)}
+}
+
+// parseForStatement parses `for name in iterable { }`, where the iterable is a
+// collection or a range such as `0..n`, `0..=n` or `0..n step 2`. Like the
+// types list and map, step is only special where it is expected.
+func (p *Parser) parseForStatement() ast.Stmt {
+	forTok, _ := p.expect(token.FOR, "expected 'for'")
+
+	name, ok := p.expect(token.IDENT, "expected loop variable name after 'for'")
+	if !ok {
+		return nil
+	}
+	if _, ok := p.expect(token.IN, "expected 'in' after loop variable"); !ok {
+		return nil
+	}
+
+	iterable := p.parseExpression()
+	if iterable == nil {
+		return nil
+	}
+	if p.check(token.DOT_DOT) || p.check(token.DOT_DOT_EQUAL) {
+		if iterable = p.parseRange(iterable); iterable == nil {
+			return nil
+		}
+	}
+
+	if !p.check(token.LBRACE) {
+		p.expect(token.LBRACE, "expected '{' after for iterable")
+		return nil
+	}
+	body := p.parseBlockStatement()
+	if body == nil {
+		return nil
+	}
+
+	return &ast.ForStmt{For: forTok, Name: name, Iterable: iterable, Body: body.(*ast.BlockStmt)}
+}
+
+// parseRange parses the rest of a range whose low bound has been parsed
+func (p *Parser) parseRange(low ast.Expr) ast.Expr {
+	r := &ast.RangeExpr{Low: low, Operator: p.advance()}
+	if r.High = p.parseExpression(); r.High == nil {
+		return nil
+	}
+	if p.check(token.IDENT) && p.peek().Lexeme == "step" {
+		p.advance()
+		if r.Step = p.parseExpression(); r.Step == nil {
+			return nil
+		}
+	}
+	return r
+}
+
+// isMapLiteralStart reports whether the '{' starting a statement opens a map
+// literal instead of a block, which is the case when a single token key is
+// followed by ':'. Longer keys are only allowed in expression position.
+func (p *Parser) isMapLiteralStart() bool {
+	offset := 1
+	for p.peekN(offset).Type == token.NEWLINE {
+		offset++
+	}
+	return p.peekN(offset+1).Type == token.COLON
+}
+
 func (p *Parser) isVarDeclStart() bool {
-	return p.check(token.IDENT) && p.peekN(1).Type == token.IDENT && p.peekN(2).Type == token.EQUAL
+	if !p.check(token.IDENT) {
+		return false
+	}
+	end := p.typeEnd(1)
+	return end > 0 && p.peekN(end).Type == token.EQUAL
+}
+
+// typeEnd returns the offset from the current token of the token that follows
+// the type starting at offset, or -1 when no type starts there
+func (p *Parser) typeEnd(offset int) int {
+	switch p.peekN(offset).Type {
+	case token.IDENT:
+		name := p.peekN(offset).Lexeme
+		if name != "list" && name != "map" || p.peekN(offset+1).Type != token.LBRACKET {
+			return offset + 1
+		}
+		offset = p.typeEnd(offset + 2)
+		if offset < 0 || p.peekN(offset).Type != token.RBRACKET {
+			return -1
+		}
+		if name == "map" {
+			return p.typeEnd(offset + 1)
+		}
+		return offset + 1
+	case token.LPAREN:
+		for {
+			offset = p.typeEnd(offset + 1)
+			if offset < 0 {
+				return -1
+			}
+			if p.peekN(offset).Type != token.COMMA {
+				break
+			}
+		}
+		if p.peekN(offset).Type != token.RPAREN {
+			return -1
+		}
+		return offset + 1
+	default:
+		return -1
+	}
+}
+
+// parseType parses a type name, a list type such as `list[int]`, a map type such
+// as `map[string]int` or a tuple type such as `(int, bool)`. A single type in
+// parentheses is that type itself.
+func (p *Parser) parseType(msg string) ast.TypeExpr {
+	if !p.check(token.LPAREN) {
+		name, ok := p.expect(token.IDENT, msg)
+		if !ok {
+			return nil
+		}
+		if name.Lexeme == "map" && p.check(token.LBRACKET) {
+			return p.parseMapType(name)
+		}
+		if name.Lexeme != "list" || !p.check(token.LBRACKET) {
+			return &ast.NamedType{Name: name}
+		}
+
+		list := &ast.ListType{Name: name, LBracket: p.advance()}
+		if list.Elem = p.parseType("expected list item type"); list.Elem == nil {
+			return nil
+		}
+		if list.RBracket, ok = p.expect(token.RBRACKET, "expected ']' after list item type"); !ok {
+			return nil
+		}
+		return list
+	}
+
+	tuple := &ast.TupleType{LParen: p.advance()}
+	for {
+		item := p.parseType("expected tuple item type")
+		if item == nil {
+			return nil
+		}
+		tuple.Items = append(tuple.Items, item)
+
+		if !p.check(token.COMMA) {
+			break
+		}
+		p.advance()
+	}
+
+	rparen, ok := p.expect(token.RPAREN, "expected ')' after tuple item types")
+	if !ok {
+		return nil
+	}
+	if len(tuple.Items) == 1 {
+		return tuple.Items[0]
+	}
+	tuple.RParen = rparen
+	return tuple
+}
+
+// parseMapType parses the rest of a map type whose name has been consumed
+func (p *Parser) parseMapType(name token.Token) ast.TypeExpr {
+	m := &ast.MapType{Name: name, LBracket: p.advance()}
+	if m.Key = p.parseType("expected map key type"); m.Key == nil {
+		return nil
+	}
+	rbracket, ok := p.expect(token.RBRACKET, "expected ']' after map key type")
+	if !ok {
+		return nil
+	}
+	m.RBracket = rbracket
+	if m.Value = p.parseType("expected map value type"); m.Value == nil {
+		return nil
+	}
+	return m
 }
 
 func (p *Parser) parseVarDeclStatement() ast.Stmt {
@@ -186,8 +424,8 @@ func (p *Parser) parseVarDeclStatement() ast.Stmt {
 		return nil
 	}
 
-	typeTok, ok := p.expect(token.IDENT, "expected type name after variable name")
-	if !ok {
+	typ := p.parseType("expected type name after variable name")
+	if typ == nil {
 		return nil
 	}
 
@@ -200,7 +438,81 @@ func (p *Parser) parseVarDeclStatement() ast.Stmt {
 		return nil
 	}
 
-	return &ast.VarDeclStmt{Name: nameTok, TypeName: typeTok, Initializer: initializer}
+	return &ast.VarDeclStmt{Name: nameTok, Type: typ, Initializer: initializer}
+}
+
+// isDestructureStart reports whether a declaration of several variables, such
+// as `q int, r int = ...` or `_, r int = ...`, starts at the current token
+func (p *Parser) isDestructureStart() bool {
+	if !p.check(token.IDENT) {
+		return false
+	}
+	if p.peek().Lexeme == "_" {
+		return p.peekN(1).Type == token.COMMA
+	}
+	end := p.typeEnd(1)
+	return end > 0 && p.peekN(end).Type == token.COMMA
+}
+
+func (p *Parser) parseDestructureStatement() ast.Stmt {
+	stmt := &ast.DestructureStmt{}
+	for {
+		nameTok, ok := p.expect(token.IDENT, "expected variable name or '_'")
+		if !ok {
+			return nil
+		}
+		target := ast.DestructureTarget{Name: nameTok}
+		if !target.Discard() {
+			if target.Type = p.parseType("expected type name after variable name"); target.Type == nil {
+				return nil
+			}
+		}
+		stmt.Targets = append(stmt.Targets, target)
+
+		if !p.check(token.COMMA) {
+			break
+		}
+		p.advance()
+	}
+
+	if _, ok := p.expect(token.EQUAL, "expected '=' after the declared variables"); !ok {
+		return nil
+	}
+
+	stmt.Value = p.parseExpression()
+	if stmt.Value == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) isAssignStart() bool {
+	return p.check(token.IDENT) && isAssignOperator(p.peekN(1).Type)
+}
+
+func isAssignOperator(tt token.Type) bool {
+	switch tt {
+	case token.EQUAL, token.PLUS_EQUAL, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL, token.PERCENT_EQUAL:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Parser) parseAssignStatement() ast.Stmt {
+	nameTok, ok := p.expect(token.IDENT, "expected variable name")
+	if !ok {
+		return nil
+	}
+
+	op := p.advance()
+
+	val := p.parseExpression()
+	if val == nil {
+		return nil
+	}
+
+	return &ast.AssignStmt{Name: nameTok, Operator: op, Value: val}
 }
 
 func (p *Parser) parseBlockStatement() ast.Stmt {
@@ -209,8 +521,10 @@ func (p *Parser) parseBlockStatement() ast.Stmt {
 
 	p.skipNewlines()
 	for !p.check(token.RBRACE) && !p.check(token.EOF) {
+		start := p.curr
 		stmt := p.parseStatement()
 		if stmt == nil {
+			p.skipFailedToken(start)
 			p.synchronize()
 			p.skipNewlines()
 			continue
@@ -230,12 +544,25 @@ func (p *Parser) parseBlockStatement() ast.Stmt {
 	return block
 }
 
+// parseExpressionStatement parses an expression used as a statement, or an
+// assignment to an indexed item such as `xs[i] = value`
 func (p *Parser) parseExpressionStatement() ast.Stmt {
 	expr := p.parseExpression()
 	if expr == nil {
 		return nil
 	}
-	return &ast.ExprStmt{Expression: expr}
+
+	target, ok := expr.(*ast.IndexExpr)
+	if !ok || !isAssignOperator(p.peek().Type) {
+		return &ast.ExprStmt{Expression: expr}
+	}
+
+	op := p.advance()
+	val := p.parseExpression()
+	if val == nil {
+		return nil
+	}
+	return &ast.IndexAssignStmt{Target: target, Operator: op, Value: val}
 }
 
 func (p *Parser) consumeStatementTerminator() bool {
@@ -248,7 +575,7 @@ func (p *Parser) consumeStatementTerminator() bool {
 	}
 
 	at := p.peek()
-	p.errs = append(p.errs, fmt.Errorf("expected newline after statement at %d:%d", at.Position.Line, at.Position.Column))
+	p.errorAt(at, diagnostics.MissingTerminator, "expected newline after statement")
 	return false
 }
 
@@ -329,7 +656,7 @@ func (p *Parser) parseTerm() ast.Expr {
 
 func (p *Parser) parseFactor() ast.Expr {
 	left := p.parseUnary()
-	for p.check(token.STAR) || p.check(token.SLASH) {
+	for p.check(token.STAR) || p.check(token.SLASH) || p.check(token.PERCENT) {
 		op := p.advance()
 		right := p.parseUnary()
 		if left == nil || right == nil {
@@ -353,16 +680,30 @@ func (p *Parser) parseUnary() ast.Expr {
 	return p.parseCall()
 }
 
+// parseCall parses a primary expression followed by any number of calls,
+// tuple indexes, indexes and slices, as in `pairs()[0].1`
 func (p *Parser) parseCall() ast.Expr {
 	expr := p.parsePrimary()
 	if expr == nil {
 		return nil
 	}
 
-	for p.check(token.LPAREN) {
+	for p.check(token.LPAREN) || p.check(token.DOT) || p.check(token.LBRACKET) {
+		if p.check(token.DOT) || p.check(token.LBRACKET) {
+			if p.check(token.DOT) {
+				expr = p.parseTupleIndex(expr)
+			} else {
+				expr = p.parseIndex(expr)
+			}
+			if expr == nil {
+				return nil
+			}
+			continue
+		}
+
 		ident, ok := expr.(*ast.Identifier)
 		if !ok {
-			p.errs = append(p.errs, fmt.Errorf("Only function identifiers can be called at %d:%d", p.peek().Position.Line, p.peek().Position.Column))
+			p.errorAt(p.peek(), diagnostics.UnexpectedToken, "only function identifiers can be called")
 			return nil
 		}
 
@@ -382,38 +723,189 @@ func (p *Parser) parseCall() ast.Expr {
 			break
 		}
 
-		if _, ok := p.expect(token.RPAREN, "expected ')' after arguments"); !ok {
+		rparen, ok := p.expect(token.RPAREN, "expected ')' after arguments")
+		if !ok {
 			return nil
 		}
 
-		expr = &ast.CallExpr{Callee: ident.Token, Arguments: args}
+		expr = &ast.CallExpr{Callee: ident.Token, Arguments: args, RParen: rparen}
 	}
 
 	return expr
 
 }
 
-func (p *Parser) parsePrimary() ast.Expr {
-	tok := p.peek()
-	switch tok.Type {
-	case token.LPAREN:
+func (p *Parser) parseTupleIndex(tuple ast.Expr) ast.Expr {
+	p.advance() // .
+	index, ok := p.expect(token.INT, "expected tuple index after '.'")
+	if !ok {
+		return nil
+	}
+	v, err := strconv.ParseUint(index.Lexeme, 10, 8)
+	if err != nil {
+		p.errorAt(index, diagnostics.InvalidLiteral, "invalid tuple index %s", index.Lexeme)
+		return nil
+	}
+	return &ast.TupleIndexExpr{Tuple: tuple, Index: index, Value: int(v)}
+}
+
+// parseIndex parses `[index]` or the slice `[low:high]` after collection
+func (p *Parser) parseIndex(collection ast.Expr) ast.Expr {
+	lbracket := p.advance()
+
+	var low ast.Expr
+	if !p.check(token.COLON) {
+		if low = p.parseExpression(); low == nil {
+			return nil
+		}
+		if !p.check(token.COLON) {
+			rbracket, ok := p.expect(token.RBRACKET, "expected ']' after index")
+			if !ok {
+				return nil
+			}
+			return &ast.IndexExpr{Collection: collection, LBracket: lbracket, Index: low, RBracket: rbracket}
+		}
+	}
+	p.advance() // :
+
+	var high ast.Expr
+	if !p.check(token.RBRACKET) {
+		if high = p.parseExpression(); high == nil {
+			return nil
+		}
+	}
+	rbracket, ok := p.expect(token.RBRACKET, "expected ']' after slice bounds")
+	if !ok {
+		return nil
+	}
+	return &ast.SliceExpr{Collection: collection, LBracket: lbracket, Low: low, High: high, RBracket: rbracket}
+}
+
+// parseList parses a list literal such as `[1, 2, 3]`
+func (p *Parser) parseList() ast.Expr {
+	list := &ast.ListLiteral{LBracket: p.advance()}
+	for !p.check(token.RBRACKET) && !p.check(token.EOF) {
+		item := p.parseExpression()
+		if item == nil {
+			return nil
+		}
+		list.Items = append(list.Items, item)
+
+		if !p.check(token.COMMA) {
+			break
+		}
 		p.advance()
-		expr := p.parseExpression()
-		if expr == nil {
+	}
+
+	rbracket, ok := p.expect(token.RBRACKET, "expected ']' after list items")
+	if !ok {
+		return nil
+	}
+	list.RBracket = rbracket
+	return list
+}
+
+// parseMap parses a map literal such as `{"a": 1, "b": 2}`. Unlike list items,
+// the entries may be written on separate lines.
+func (p *Parser) parseMap() ast.Expr {
+	m := &ast.MapLiteral{LBrace: p.advance()}
+	p.skipNewlines()
+	for !p.check(token.RBRACE) && !p.check(token.EOF) {
+		key := p.parseExpression()
+		if key == nil {
+			return nil
+		}
+		if _, ok := p.expect(token.COLON, "expected ':' after map key"); !ok {
 			return nil
 		}
+		v := p.parseExpression()
+		if v == nil {
+			return nil
+		}
+		m.Entries = append(m.Entries, ast.MapEntry{Key: key, Value: v})
+
+		p.skipNewlines()
+		if !p.check(token.COMMA) {
+			break
+		}
+		p.advance()
+		p.skipNewlines()
+	}
+
+	rbrace, ok := p.expect(token.RBRACE, "expected '}' after map entries")
+	if !ok {
+		return nil
+	}
+	m.RBrace = rbrace
+	return m
+}
+
+// parseGroup parses an expression in parentheses, or a tuple literal when the
+// parentheses hold several expressions separated by commas
+func (p *Parser) parseGroup() ast.Expr {
+	lparen := p.advance()
+	expr := p.parseExpression()
+	if expr == nil {
+		return nil
+	}
+	if !p.check(token.COMMA) {
 		if _, ok := p.expect(token.RPAREN, "expected ')' after expression"); !ok {
 			return nil
 		}
 		return expr
+	}
+
+	tuple := &ast.TupleLiteral{LParen: lparen, Items: []ast.Expr{expr}}
+	for p.check(token.COMMA) {
+		p.advance()
+		item := p.parseExpression()
+		if item == nil {
+			return nil
+		}
+		tuple.Items = append(tuple.Items, item)
+	}
+
+	rparen, ok := p.expect(token.RPAREN, "expected ')' after tuple items")
+	if !ok {
+		return nil
+	}
+	tuple.RParen = rparen
+	return tuple
+}
+
+func (p *Parser) parsePrimary() ast.Expr {
+	tok := p.peek()
+	switch tok.Type {
+	case token.LPAREN:
+		return p.parseGroup()
+	case token.LBRACKET:
+		return p.parseList()
+	case token.LBRACE:
+		return p.parseMap()
 	case token.INT:
 		p.advance()
 		v, err := strconv.ParseInt(tok.Lexeme, 10, 64)
 		if err != nil {
-			p.errs = append(p.errs, fmt.Errorf("invalid integer %q at %d:%d", tok.Lexeme, tok.Position.Line, tok.Position.Column))
+			p.errorAt(tok, diagnostics.InvalidLiteral, "invalid integer %q", tok.Lexeme)
 			return nil
 		}
 		return &ast.IntLiteral{Token: tok, Value: v}
+	case token.FLOAT:
+		p.advance()
+		v, err := strconv.ParseFloat(tok.Lexeme, 64)
+		if err != nil {
+			p.errorAt(tok, diagnostics.InvalidLiteral, "invalid float %q", tok.Lexeme)
+			return nil
+		}
+		return &ast.FloatLiteral{Token: tok, Value: v}
+	case token.STRING:
+		p.advance()
+		v, err := lexer.Unquote(tok.Lexeme)
+		if err != nil {
+			p.errorAt(tok, diagnostics.InvalidLiteral, "invalid string %s: %v", tok.Lexeme, err)
+			return nil
+		}
+		return &ast.StringLiteral{Token: tok, Value: v}
 	case token.TRUE:
 		p.advance()
 		return &ast.BoolLiteral{Token: tok, Value: true}
@@ -427,11 +919,19 @@ func (p *Parser) parsePrimary() ast.Expr {
 		p.advance()
 		return &ast.Identifier{Token: tok, Name: tok.Lexeme}
 	default:
-		p.errs = append(p.errs, fmt.Errorf("unexpected token %s (%q) at %d:%d", tok.Type, tok.Lexeme, tok.Position.Line, tok.Position.Column))
+		p.errorAt(tok, diagnostics.UnexpectedToken, "unexpected token %s (%q)", tok.Type, tok.Lexeme)
 		return nil
 	}
 }
 
+// skipFailedToken consumes the current token when a statement failed without
+// consuming anything, so synchronize cannot stop at the same place forever.
+func (p *Parser) skipFailedToken(start int) {
+	if p.curr == start {
+		p.advance()
+	}
+}
+
 func (p *Parser) synchronize() {
 	for !p.check(token.EOF) {
 		if p.previous().Type == token.NEWLINE {
@@ -450,10 +950,23 @@ func (p *Parser) expect(tt token.Type, msg string) (token.Token, bool) {
 		return p.advance(), true
 	}
 	at := p.peek()
-	p.errs = append(p.errs, fmt.Errorf("%s at %d:%d", msg, at.Position.Line, at.Position.Column))
+	p.errorAt(at, diagnostics.ExpectedToken, "%s", msg)
 	return token.Token{}, false
 }
 
+// errorAt records a diagnostic covering tok. ILLEGAL tokens already reported by
+// the lexer are skipped, so a bad character does not produce a second error.
+func (p *Parser) errorAt(tok token.Token, code diagnostics.Code, format string, args ...any) {
+	if tok.Type == token.ILLEGAL {
+		for _, d := range p.errs {
+			if d.Span.Start == tok.Position {
+				return
+			}
+		}
+	}
+	p.errs = append(p.errs, diagnostics.Errorf(code, diagnostics.TokenSpan(tok), format, args...))
+}
+
 func (p *Parser) check(tt token.Type) bool {
 	return p.peek().Type == tt
 }
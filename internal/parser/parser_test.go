@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
 	"github.com/rafa-ribeiro/brasalang/internal/token"
 )
 
@@ -32,8 +33,28 @@ func TestParseTypedVarDeclaration(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected VarDeclStmt, got %T", program.Statements[0])
 	}
-	if decl.Name.Lexeme != "idade" || decl.TypeName.Lexeme != "int" {
-		t.Fatalf("unexpected declaration: name=%s type=%s", decl.Name.Lexeme, decl.TypeName.Lexeme)
+	if decl.Name.Lexeme != "idade" || decl.Type.String() != "int" {
+		t.Fatalf("unexpected declaration: name=%s type=%s", decl.Name.Lexeme, decl.Type)
+	}
+}
+
+func TestParseDestructuringDeclaration(t *testing.T) {
+	p := NewFromSource("q int, _, r int = divmod(7, 2)\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	decl, ok := program.Statements[0].(*ast.DestructureStmt)
+	if !ok {
+		t.Fatalf("expected DestructureStmt, got %T", program.Statements[0])
+	}
+	if len(decl.Targets) != 3 || decl.Targets[0].Type.String() != "int" || !decl.Targets[1].Discard() || decl.Targets[2].Name.Lexeme != "r" {
+		t.Fatalf("unexpected targets: %+v", decl.Targets)
+	}
+	if _, ok := decl.Value.(*ast.CallExpr); !ok {
+		t.Fatalf("expected CallExpr value, got %T", decl.Value)
 	}
 }
 
@@ -146,3 +167,263 @@ func TestParseMultipleReturnTypes(t *testing.T) {
 		t.Fatalf("expected 2 return types, got %d", got)
 	}
 }
+
+func TestParseTupleTypesAndLiterals(t *testing.T) {
+	p := NewFromSource("t (int, (bool, string)) = (1, (true, \"a\"))\nt.1.0\n(t)\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+
+	decl := program.Statements[0].(*ast.VarDeclStmt)
+	if got := decl.Type.String(); got != "(int, (bool, string))" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	tuple, ok := decl.Initializer.(*ast.TupleLiteral)
+	if !ok || len(tuple.Items) != 2 {
+		t.Fatalf("expected a TupleLiteral of 2 items, got %s", ast.Dump(decl.Initializer))
+	}
+	if _, ok := tuple.Items[1].(*ast.TupleLiteral); !ok {
+		t.Fatalf("expected a nested TupleLiteral, got %T", tuple.Items[1])
+	}
+
+	outer, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.TupleIndexExpr)
+	if !ok || outer.Value != 0 {
+		t.Fatalf("expected t.1.0, got %s", ast.Dump(program.Statements[1]))
+	}
+	if inner, ok := outer.Tuple.(*ast.TupleIndexExpr); !ok || inner.Value != 1 {
+		t.Fatalf("expected t.1 inside t.1.0, got %s", ast.Dump(outer.Tuple))
+	}
+
+	if _, ok := program.Statements[2].(*ast.ExprStmt).Expression.(*ast.Identifier); !ok {
+		t.Fatalf("expected a parenthesized identifier, got %s", ast.Dump(program.Statements[2]))
+	}
+}
+
+func TestParseTupleReturnTypes(t *testing.T) {
+	p := NewFromSource("def f(p (int, int)) -> ((int, int), bool) {\n  return p, true\n}\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	fn := program.Statements[0].(*ast.FuncDeclStmt)
+	if got := fn.Params[0].Type.String(); got != "(int, int)" {
+		t.Fatalf("unexpected parameter type %s", got)
+	}
+	if len(fn.ReturnTypes) != 2 || fn.ReturnTypes[0].String() != "(int, int)" {
+		t.Fatalf("unexpected return types %v", fn.ReturnTypes)
+	}
+}
+
+func TestParseListsIndexesAndSlices(t *testing.T) {
+	p := NewFromSource("xs list[list[int]] = [[1], []]\nxs[0][1] += 2\nxs[1:]\nxs[:n]\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+
+	decl := program.Statements[0].(*ast.VarDeclStmt)
+	if got := decl.Type.String(); got != "list[list[int]]" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	if list, ok := decl.Initializer.(*ast.ListLiteral); !ok || len(list.Items) != 2 {
+		t.Fatalf("expected a ListLiteral of 2 items, got %s", ast.Dump(decl.Initializer))
+	}
+
+	assign, ok := program.Statements[1].(*ast.IndexAssignStmt)
+	if !ok || assign.Operator.Type != token.PLUS_EQUAL {
+		t.Fatalf("expected an IndexAssignStmt, got %s", ast.Dump(program.Statements[1]))
+	}
+	if _, ok := assign.Target.Collection.(*ast.IndexExpr); !ok {
+		t.Fatalf("expected xs[0] as the collection, got %s", ast.Dump(assign.Target.Collection))
+	}
+
+	from := program.Statements[2].(*ast.ExprStmt).Expression.(*ast.SliceExpr)
+	if from.Low == nil || from.High != nil {
+		t.Fatalf("expected xs[1:] to omit its high bound, got %s", ast.Dump(from))
+	}
+	upTo := program.Statements[3].(*ast.ExprStmt).Expression.(*ast.SliceExpr)
+	if upTo.Low != nil || upTo.High == nil {
+		t.Fatalf("expected xs[:n] to omit its low bound, got %s", ast.Dump(upTo))
+	}
+}
+
+func TestParseMapsAndBlocks(t *testing.T) {
+	p := NewFromSource("m map[string]list[int] = {\n  \"a\": [1],\n  \"b\": [],\n}\n{\"c\": [2]}\n{\n  m\n}\nm[\"a\"] = {}\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+
+	decl := program.Statements[0].(*ast.VarDeclStmt)
+	if got := decl.Type.String(); got != "map[string]list[int]" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	if m, ok := decl.Initializer.(*ast.MapLiteral); !ok || len(m.Entries) != 2 {
+		t.Fatalf("expected a MapLiteral of 2 entries, got %s", ast.Dump(decl.Initializer))
+	}
+	if _, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.MapLiteral); !ok {
+		t.Fatalf("expected a map literal statement, got %s", ast.Dump(program.Statements[1]))
+	}
+	if _, ok := program.Statements[2].(*ast.BlockStmt); !ok {
+		t.Fatalf("expected a block, got %s", ast.Dump(program.Statements[2]))
+	}
+	assign := program.Statements[3].(*ast.IndexAssignStmt)
+	if m, ok := assign.Value.(*ast.MapLiteral); !ok || len(m.Entries) != 0 {
+		t.Fatalf("expected an empty MapLiteral, got %s", ast.Dump(assign.Value))
+	}
+}
+
+func TestParseIfElseIfElseChain(t *testing.T) {
+	p := NewFromSource("if a < 1 {\n  1\n} else if a < 2 {\n  2\n} else {\n  3\n}\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	stmt, ok := program.Statements[0].(*ast.IfStmt)
+	if !ok {
+		t.Fatalf("expected IfStmt, got %T", program.Statements[0])
+	}
+	if len(stmt.Then.Statements) != 1 {
+		t.Fatalf("expected 1 statement in then branch, got %d", len(stmt.Then.Statements))
+	}
+
+	elseIf, ok := stmt.Else.(*ast.IfStmt)
+	if !ok {
+		t.Fatalf("expected else-if branch, got %T", stmt.Else)
+	}
+	if _, ok := elseIf.Else.(*ast.BlockStmt); !ok {
+		t.Fatalf("expected final else block, got %T", elseIf.Else)
+	}
+}
+
+func TestParseIfRequiresBlock(t *testing.T) {
+	p := NewFromSource("if true 1\n")
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parse error for if without block")
+	}
+}
+
+func TestParseWhileWithBreakAndContinue(t *testing.T) {
+	p := NewFromSource("while a < 10 {\n  continue\n  break\n}\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	loop, ok := program.Statements[0].(*ast.WhileStmt)
+	if !ok {
+		t.Fatalf("expected WhileStmt, got %T", program.Statements[0])
+	}
+	if _, ok := loop.Body.Statements[0].(*ast.ContinueStmt); !ok {
+		t.Fatalf("expected ContinueStmt, got %T", loop.Body.Statements[0])
+	}
+	if _, ok := loop.Body.Statements[1].(*ast.BreakStmt); !ok {
+		t.Fatalf("expected BreakStmt, got %T", loop.Body.Statements[1])
+	}
+}
+
+func TestParseForLoops(t *testing.T) {
+	p := NewFromSource("for i in 0..=n + 1 step 2 {\n  i\n}\nfor x in xs[1:] {\n  break\n}\nfor step in 0..3 {\n}\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+
+	loop := program.Statements[0].(*ast.ForStmt)
+	r, ok := loop.Iterable.(*ast.RangeExpr)
+	if !ok || !r.Inclusive() || r.Step == nil {
+		t.Fatalf("expected an inclusive range with a step, got %s", ast.Dump(loop.Iterable))
+	}
+	if _, ok := r.High.(*ast.BinaryExpr); !ok {
+		t.Fatalf("expected n + 1 as the high bound, got %s", ast.Dump(r.High))
+	}
+	if _, ok := program.Statements[1].(*ast.ForStmt).Iterable.(*ast.SliceExpr); !ok {
+		t.Fatalf("expected a slice as the iterable, got %s", ast.Dump(program.Statements[1]))
+	}
+	if loop := program.Statements[2].(*ast.ForStmt); loop.Name.Lexeme != "step" || loop.Iterable.(*ast.RangeExpr).Step != nil {
+		t.Fatalf("expected step to be an ordinary name outside ranges, got %s", ast.Dump(loop))
+	}
+}
+
+func TestParseForRequiresIn(t *testing.T) {
+	p := NewFromSource("for i 0..3 {\n}\n")
+	p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) == 0 || errs[0].Message != "expected 'in' after loop variable" {
+		t.Fatalf("expected a missing 'in' error, got %v", p.Errors())
+	}
+}
+
+func TestParseAssignmentStatements(t *testing.T) {
+	p := NewFromSource("x = 1\nx += 2\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	wantOps := []token.Type{token.EQUAL, token.PLUS_EQUAL}
+	for i, want := range wantOps {
+		assign, ok := program.Statements[i].(*ast.AssignStmt)
+		if !ok {
+			t.Fatalf("expected AssignStmt, got %T", program.Statements[i])
+		}
+		if assign.Name.Lexeme != "x" || assign.Operator.Type != want {
+			t.Fatalf("unexpected assignment: name=%s op=%s", assign.Name.Lexeme, assign.Operator.Type)
+		}
+	}
+}
+
+func TestParseErrorsAreDiagnosticsWithSpans(t *testing.T) {
+	p := NewFromSource("x int = \"open\ndef BadName() {\n}\n")
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(errs), errs)
+	}
+
+	if errs[0].Code != diagnostics.UnterminatedString || errs[0].Span.Start.Column != 9 || errs[0].Span.End.Column != 14 {
+		t.Fatalf("unexpected lexer diagnostic: %+v", errs[0])
+	}
+	if errs[1].Code != diagnostics.InvalidName || errs[1].Span.Start.Line != 2 || errs[1].Span.Start.Column != 5 {
+		t.Fatalf("unexpected parser diagnostic: %+v", errs[1])
+	}
+}
+
+func TestParseRecoversFromStrayClosingBrace(t *testing.T) {
+	p := NewFromSource("}\n{\n)\n}\nok\n")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(p.Errors()), p.Errors())
+	}
+	last := program.Statements[len(program.Statements)-1].(*ast.ExprStmt)
+	if ident, ok := last.Expression.(*ast.Identifier); !ok || ident.Name != "ok" {
+		t.Fatalf("expected parsing to resume at the last line, got %#v", last.Expression)
+	}
+}
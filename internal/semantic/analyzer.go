@@ -1,22 +1,1039 @@
+// Package semantic implements the static checks performed on a parsed Brasa program
+// before it is compiled: name resolution and type checking of declarations,
+// operators, calls and returns.
 package semantic
 
-import "github.com/rafa-ribeiro/brasalang/internal/ast"
+import (
+	"maps"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/ast"
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
 
 type Type string
 
 const (
-	TypeInt  Type = "int"
-	TypeBool Type = "bool"
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	TypeBool   Type = "bool"
+	TypeString Type = "string"
+	TypeNil    Type = "nil" // type of nil and of calls to functions without return values
+
+	// emptyList is the type of the literal [], which can be used as a list of any type
+	emptyList Type = "list[]"
+	// emptyMap is the type of the literal {}, which can be used as a map of any type
+	emptyMap Type = "map[]"
 )
 
-type Analyzer struct{}
+// typeNames holds the types that can be written in declarations
+var typeNames = map[string]Type{
+	"int":    TypeInt,
+	"float":  TypeFloat,
+	"bool":   TypeBool,
+	"string": TypeString,
+}
+
+// conversions are the built-in functions converting a numeric value to another numeric type
+var conversions = map[string]Type{
+	"int":   TypeInt,
+	"float": TypeFloat,
+}
+
+// collectionBuiltins are the built-in functions working on lists, maps and
+// strings, with the number of arguments they take
+var collectionBuiltins = map[string]int{
+	"len":    1,
+	"append": 2,
+	"has":    2,
+	"delete": 2,
+	"keys":   1,
+}
+
+// signature describes the parameter and return types of a function
+type signature struct {
+	Params  []Type
+	Returns []Type
+	Name    token.Token // name token of the declaration
+}
+
+type Analyzer struct {
+	errs      diagnostics.List
+	globals   map[string]Type      // globals declared so far, in program order
+	hoisted   map[string]Type      // top-level globals, visible inside every function body
+	functions map[string]signature // every function declared in the program
+	locals    map[string]Type      // locals of the function being analyzed, nil at top level
+	fn        *ast.FuncDeclStmt    // function being analyzed, nil at top level
+	loopDepth int
+}
 
 func New() *Analyzer {
-	return &Analyzer{}
+	return &Analyzer{globals: map[string]Type{}, hoisted: map[string]Type{}, functions: map[string]signature{}}
+}
+
+// Analyze type checks the program and returns every error found. Declarations
+// of a program without errors are kept by the analyzer, so later programs can
+// refer to them.
+func (a *Analyzer) Analyze(program *ast.Program) diagnostics.List {
+	a.errs = nil
+
+	globals, hoisted, functions := maps.Clone(a.globals), maps.Clone(a.hoisted), maps.Clone(a.functions)
+	defer func() {
+		if len(a.errs) > 0 {
+			a.globals, a.hoisted, a.functions = globals, hoisted, functions
+		}
+	}()
+
+	for _, stmt := range program.Statements {
+		switch node := stmt.(type) {
+		case *ast.FuncDeclStmt:
+			a.declareFunction(node)
+		case *ast.VarDeclStmt:
+			a.hoist(node.Name, node.Type)
+		case *ast.DestructureStmt:
+			for _, target := range node.Targets {
+				if !target.Discard() {
+					a.hoist(target.Name, target.Type)
+				}
+			}
+		}
+	}
+
+	for _, stmt := range program.Statements {
+		if fn, ok := stmt.(*ast.FuncDeclStmt); ok {
+			a.checkFunction(fn)
+			continue
+		}
+		a.checkStmt(stmt)
+	}
+
+	return a.errs
+}
+
+// hoist makes the top-level global name visible inside every function body
+func (a *Analyzer) hoist(name token.Token, typeExpr ast.TypeExpr) {
+	typ, invalid := typeOf(typeExpr)
+	if invalid != nil {
+		return
+	}
+	if _, exists := a.hoisted[name.Lexeme]; !exists {
+		a.hoisted[name.Lexeme] = typ
+	}
+}
+
+func (a *Analyzer) declareFunction(fn *ast.FuncDeclStmt) {
+	if previous, exists := a.functions[fn.Name.Lexeme]; exists {
+		a.errorf(diagnostics.TokenSpan(fn.Name), diagnostics.Redeclaration, "function %q already declared", fn.Name.Lexeme).
+			WithRelated(diagnostics.TokenSpan(previous.Name), "previous declaration of %q is here", fn.Name.Lexeme)
+		return
+	}
+	_, isCollectionBuiltin := collectionBuiltins[fn.Name.Lexeme]
+	if _, exists := conversions[fn.Name.Lexeme]; exists || isCollectionBuiltin {
+		a.errorf(diagnostics.TokenSpan(fn.Name), diagnostics.Redeclaration, "function %q shadows a built-in function", fn.Name.Lexeme)
+		return
+	}
+
+	sig := signature{Name: fn.Name}
+	for _, p := range fn.Params {
+		typ, _ := a.resolveType(p.Type)
+		sig.Params = append(sig.Params, typ)
+	}
+	for _, r := range fn.ReturnTypes {
+		typ, _ := a.resolveType(r)
+		sig.Returns = append(sig.Returns, typ)
+	}
+	a.functions[fn.Name.Lexeme] = sig
+}
+
+func (a *Analyzer) checkFunction(fn *ast.FuncDeclStmt) {
+	a.fn = fn
+	a.locals = map[string]Type{}
+	defer func() {
+		a.fn = nil
+		a.locals = nil
+	}()
+
+	// declareFunction resolved, and reported, the parameter types already
+	params := a.functions[fn.Name.Lexeme].Params
+	for i, p := range fn.Params {
+		if _, exists := a.locals[p.Name.Lexeme]; exists {
+			a.errorf(diagnostics.TokenSpan(p.Name), diagnostics.Redeclaration, "parameter %q already declared", p.Name.Lexeme)
+			continue
+		}
+		var typ Type
+		if i < len(params) {
+			typ = params[i]
+		}
+		a.locals[p.Name.Lexeme] = typ
+	}
+
+	for _, stmt := range fn.Body.Statements {
+		a.checkStmt(stmt)
+	}
+
+	if len(fn.ReturnTypes) > 0 && !terminates(fn.Body) {
+		a.errorf(diagnostics.TokenSpan(fn.Name), diagnostics.MissingReturn, "function %q must return a value on every path", fn.Name.Lexeme)
+	}
+}
+
+func (a *Analyzer) checkStmt(stmt ast.Stmt) {
+	switch node := stmt.(type) {
+	case *ast.ExprStmt:
+		a.exprType(node.Expression)
+
+	case *ast.VarDeclStmt:
+		declared, ok := a.resolveType(node.Type)
+		initType := a.exprType(node.Initializer)
+		if ok && initType != "" && !assignable(initType, declared) {
+			a.errorf(diagnostics.NodeSpan(node.Initializer), diagnostics.TypeMismatch, "cannot use %s value as %s in declaration of %q", initType, declared, node.Name.Lexeme)
+		}
+		a.declareVariable(node.Name, declared)
+
+	case *ast.DestructureStmt:
+		a.checkDestructure(node)
+
+	case *ast.AssignStmt:
+		target, ok := a.lookup(node.Name.Lexeme)
+		valueType := a.exprType(node.Value)
+		if !ok {
+			a.errorf(diagnostics.TokenSpan(node.Name), diagnostics.UndeclaredName, "cannot assign to undeclared variable %q", node.Name.Lexeme)
+			return
+		}
+		if node.Operator.Type != token.EQUAL && valueType != "" {
+			valueType = a.binaryType(node.Operator, target, valueType)
+		}
+		if valueType != "" && target != "" && !assignable(valueType, target) {
+			a.errorf(diagnostics.NodeSpan(node.Value), diagnostics.TypeMismatch, "cannot assign %s value to variable %q of type %s", valueType, node.Name.Lexeme, target)
+		}
+
+	case *ast.IndexAssignStmt:
+		target := a.exprType(node.Target)
+		valueType := a.exprType(node.Value)
+		if target == "" || valueType == "" {
+			return
+		}
+		if node.Operator.Type != token.EQUAL {
+			valueType = a.binaryType(node.Operator, target, valueType)
+		}
+		if valueType != "" && !assignable(valueType, target) {
+			a.errorf(diagnostics.NodeSpan(node.Value), diagnostics.TypeMismatch, "cannot assign %s value to item of type %s", valueType, target)
+		}
+
+	case *ast.BlockStmt:
+		for _, inner := range node.Statements {
+			a.checkStmt(inner)
+		}
+
+	case *ast.IfStmt:
+		a.checkCondition(node.Condition, "if")
+		a.checkStmt(node.Then)
+		if node.Else != nil {
+			a.checkStmt(node.Else)
+		}
+
+	case *ast.WhileStmt:
+		a.checkCondition(node.Condition, "while")
+		a.loopDepth++
+		a.checkStmt(node.Body)
+		a.loopDepth--
+
+	case *ast.ForStmt:
+		a.checkFor(node)
+
+	case *ast.BreakStmt:
+		if a.loopDepth == 0 {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.MisplacedStatement, "break statement is only allowed inside loops")
+		}
+
+	case *ast.ContinueStmt:
+		if a.loopDepth == 0 {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.MisplacedStatement, "continue statement is only allowed inside loops")
+		}
+
+	case *ast.ReturnStmt:
+		a.checkReturn(node)
+
+	case *ast.FuncDeclStmt:
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.MisplacedStatement, "function %q must be declared at top level", node.Name.Lexeme)
+
+	default:
+		a.errorf(diagnostics.NodeSpan(stmt), diagnostics.MisplacedStatement, "unsupported statement type %T", stmt)
+	}
+}
+
+// checkDestructure checks that the value of node is a tuple with one item of
+// the declared type for each target
+func (a *Analyzer) checkDestructure(node *ast.DestructureStmt) {
+	declared := make([]Type, len(node.Targets))
+	for i, target := range node.Targets {
+		if !target.Discard() {
+			declared[i], _ = a.resolveType(target.Type)
+		}
+	}
+
+	valueType := a.exprType(node.Value)
+	values, isTuple := tupleItems(valueType)
+
+	switch {
+	case valueType == "":
+	case !isTuple:
+		a.errorf(diagnostics.NodeSpan(node.Value), diagnostics.ValueCount, "cannot destructure %s value, expected a tuple", valueType)
+	case len(values) != len(node.Targets):
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.ValueCount, "cannot destructure %d values into %d variables", len(values), len(node.Targets))
+	default:
+		for i, target := range node.Targets {
+			if declared[i] != "" && !assignable(values[i], declared[i]) {
+				a.errorf(diagnostics.NodeSpan(target.Type), diagnostics.TypeMismatch, "cannot use %s value as %s in declaration of %q", values[i], declared[i], target.Name.Lexeme)
+			}
+		}
+	}
+
+	for i, target := range node.Targets {
+		if !target.Discard() {
+			a.declareVariable(target.Name, declared[i])
+		}
+	}
+}
+
+func (a *Analyzer) checkReturn(node *ast.ReturnStmt) {
+	if a.fn == nil {
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.MisplacedStatement, "return statement is only allowed inside functions")
+		return
+	}
+
+	sig := a.functions[a.fn.Name.Lexeme]
+	if len(node.Values) == 1 && len(sig.Returns) > 1 {
+		// a tuple holding every return value can be returned as is
+		got := a.exprType(node.Values[0])
+		if _, isTuple := tupleItems(got); isTuple {
+			if want := tupleOf(sig.Returns); !assignable(got, want) {
+				a.errorf(diagnostics.NodeSpan(node.Values[0]), diagnostics.ReturnMismatch, "function %q must return %s, got %s", a.fn.Name.Lexeme, want, got)
+			}
+			return
+		}
+		if got != "" {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.ReturnMismatch, "function %q return expects %d value(s), got 1", a.fn.Name.Lexeme, len(sig.Returns))
+		}
+		return
+	}
+	if len(node.Values) != len(sig.Returns) {
+		if len(sig.Returns) == 0 {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.ReturnMismatch, "void function %q cannot return a value", a.fn.Name.Lexeme)
+		} else {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.ReturnMismatch, "function %q return expects %d value(s), got %d", a.fn.Name.Lexeme, len(sig.Returns), len(node.Values))
+		}
+		for _, v := range node.Values {
+			a.exprType(v)
+		}
+		return
+	}
+
+	for i, v := range node.Values {
+		got := a.exprType(v)
+		if got != "" && sig.Returns[i] != "" && !assignable(got, sig.Returns[i]) {
+			a.errorf(diagnostics.NodeSpan(v), diagnostics.ReturnMismatch, "function %q return value %d must be %s, got %s", a.fn.Name.Lexeme, i+1, sig.Returns[i], got)
+		}
+	}
+}
+
+func (a *Analyzer) checkCondition(cond ast.Expr, keyword string) {
+	if typ := a.exprType(cond); typ != "" && typ != TypeBool {
+		a.errorf(diagnostics.NodeSpan(cond), diagnostics.TypeMismatch, "%s condition must be bool, got %s", keyword, typ)
+	}
+}
+
+// exprType returns the type of the expression, or an empty Type when it is
+// invalid. Errors are only reported where they originate, so an empty operand
+// type never produces a second error.
+func (a *Analyzer) exprType(expr ast.Expr) Type {
+	switch node := expr.(type) {
+	case *ast.IntLiteral:
+		return TypeInt
+	case *ast.FloatLiteral:
+		return TypeFloat
+	case *ast.BoolLiteral:
+		return TypeBool
+	case *ast.StringLiteral:
+		return TypeString
+	case *ast.NilLiteral:
+		return TypeNil
+
+	case *ast.Identifier:
+		typ, ok := a.lookup(node.Name)
+		if !ok {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.UndeclaredName, "identifier %q is not declared", node.Name)
+			return ""
+		}
+		return typ
+
+	case *ast.UnaryExpr:
+		right := a.exprType(node.Right)
+		if right == "" {
+			return ""
+		}
+		if node.Operator.Type == token.NOT {
+			if right != TypeBool {
+				a.errorf(diagnostics.NodeSpan(node), diagnostics.InvalidOperand, "operator ! requires bool operand, got %s", right)
+				return ""
+			}
+			return TypeBool
+		}
+		if !isNumeric(right) {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.InvalidOperand, "operator %s requires numeric operand, got %s", node.Operator.Lexeme, right)
+			return ""
+		}
+		return right
+
+	case *ast.BinaryExpr:
+		left, right := a.exprType(node.Left), a.exprType(node.Right)
+		if left == "" || right == "" {
+			return ""
+		}
+		return a.binaryType(node.Operator, left, right)
+
+	case *ast.CallExpr:
+		return a.callType(node)
+
+	case *ast.TupleLiteral:
+		items := make([]Type, len(node.Items))
+		valid := true
+		for i, item := range node.Items {
+			items[i] = a.exprType(item)
+			valid = valid && items[i] != ""
+		}
+		if !valid {
+			return ""
+		}
+		return tupleOf(items)
+
+	case *ast.ListLiteral:
+		if len(node.Items) == 0 {
+			return emptyList
+		}
+		first := a.exprType(node.Items[0])
+		valid := first != ""
+		for _, item := range node.Items[1:] {
+			typ := a.exprType(item)
+			if typ == "" || first == "" {
+				valid = false
+				continue
+			}
+			if !assignable(typ, first) {
+				a.errorf(diagnostics.NodeSpan(item), diagnostics.TypeMismatch, "list items must all be %s, got %s", first, typ)
+				valid = false
+			}
+		}
+		if !valid {
+			return ""
+		}
+		return listOf(first)
+
+	case *ast.MapLiteral:
+		return a.mapLiteralType(node)
+
+	case *ast.IndexExpr:
+		collection, index := a.exprType(node.Collection), a.exprType(node.Index)
+		if key, val, isMap := mapTypes(collection); isMap {
+			switch {
+			case collection == emptyMap:
+				a.errorf(diagnostics.NodeSpan(node.Collection), diagnostics.InvalidOperand, "cannot index an empty map literal")
+				return ""
+			case index != "" && !assignable(index, key):
+				a.errorf(diagnostics.NodeSpan(node.Index), diagnostics.TypeMismatch, "map key must be %s, got %s", key, index)
+				return ""
+			}
+			return val
+		}
+		if _, isList := listElem(collection); !isList && collection != "" {
+			a.errorf(diagnostics.NodeSpan(node.Collection), diagnostics.InvalidOperand, "cannot index %s value, expected a list or map", collection)
+			return ""
+		}
+		elem := a.listItemType(node.Collection, collection, "index")
+		if index != "" && index != TypeInt {
+			a.errorf(diagnostics.NodeSpan(node.Index), diagnostics.TypeMismatch, "list index must be int, got %s", index)
+			return ""
+		}
+		return elem
+
+	case *ast.SliceExpr:
+		collection := a.exprType(node.Collection)
+		valid := a.listItemType(node.Collection, collection, "slice") != ""
+		for _, bound := range []ast.Expr{node.Low, node.High} {
+			if bound == nil {
+				continue
+			}
+			if typ := a.exprType(bound); typ != "" && typ != TypeInt {
+				a.errorf(diagnostics.NodeSpan(bound), diagnostics.TypeMismatch, "slice bound must be int, got %s", typ)
+				valid = false
+			}
+		}
+		if !valid {
+			return ""
+		}
+		return collection
+
+	case *ast.TupleIndexExpr:
+		tuple := a.exprType(node.Tuple)
+		if tuple == "" {
+			return ""
+		}
+		items, ok := tupleItems(tuple)
+		if !ok {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.InvalidOperand, "cannot index %s value with .%d, expected a tuple", tuple, node.Value)
+			return ""
+		}
+		if node.Value >= len(items) {
+			a.errorf(diagnostics.TokenSpan(node.Index), diagnostics.InvalidOperand, "index %d out of range for %s", node.Value, tuple)
+			return ""
+		}
+		return items[node.Value]
+
+	default:
+		a.errorf(diagnostics.NodeSpan(expr), diagnostics.InvalidOperand, "unsupported expression type %T", expr)
+		return ""
+	}
+}
+
+// mapLiteralType returns the type of a map literal, whose keys must all have
+// the same type, which can be used as a map key, and so must its values
+func (a *Analyzer) mapLiteralType(node *ast.MapLiteral) Type {
+	if len(node.Entries) == 0 {
+		return emptyMap
+	}
+
+	var key, val Type
+	valid := true
+	for i, entry := range node.Entries {
+		keyType, valType := a.exprType(entry.Key), a.exprType(entry.Value)
+		switch {
+		case keyType == "":
+			valid = false
+		case !isMapKey(keyType):
+			a.errorf(diagnostics.NodeSpan(entry.Key), diagnostics.TypeMismatch, "map key must be int, bool or string, got %s", keyType)
+			valid = false
+		case i == 0:
+			key = keyType
+		case key != "" && keyType != key:
+			a.errorf(diagnostics.NodeSpan(entry.Key), diagnostics.TypeMismatch, "map keys must all be %s, got %s", key, keyType)
+			valid = false
+		}
+
+		switch {
+		case valType == "":
+			valid = false
+		case i == 0:
+			val = valType
+		case val != "" && !assignable(valType, val):
+			a.errorf(diagnostics.NodeSpan(entry.Value), diagnostics.TypeMismatch, "map values must all be %s, got %s", val, valType)
+			valid = false
+		}
+	}
+	if !valid {
+		return ""
+	}
+	return mapOf(key, val)
+}
+
+// binaryType returns the result type of applying op to operands of the given types.
+// Compound assignment operators are checked as their arithmetic counterparts.
+func (a *Analyzer) binaryType(op token.Token, left, right Type) Type {
+	switch op.Type {
+	case token.PLUS, token.PLUS_EQUAL:
+		if left == TypeString && right == TypeString {
+			return TypeString
+		}
+		fallthrough
+	case token.MINUS, token.STAR, token.SLASH, token.PERCENT, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL, token.PERCENT_EQUAL:
+		if !isNumeric(left) || !isNumeric(right) {
+			a.errorf(diagnostics.TokenSpan(op), diagnostics.InvalidOperand, "operator %s is not defined for %s and %s", op.Lexeme, left, right)
+			return ""
+		}
+		if left == TypeFloat || right == TypeFloat {
+			return TypeFloat
+		}
+		return TypeInt
+
+	case token.GREATER, token.GREATER_EQ, token.LESS, token.LESS_EQ:
+		if (isNumeric(left) && isNumeric(right)) || (left == TypeString && right == TypeString) {
+			return TypeBool
+		}
+		a.errorf(diagnostics.TokenSpan(op), diagnostics.InvalidOperand, "operator %s is not defined for %s and %s", op.Lexeme, left, right)
+		return ""
+
+	case token.EQUAL_EQUAL, token.NOT_EQUAL:
+		if assignable(left, right) || assignable(right, left) || (isNumeric(left) && isNumeric(right)) {
+			return TypeBool
+		}
+		a.errorf(diagnostics.TokenSpan(op), diagnostics.InvalidOperand, "cannot compare %s with %s", left, right)
+		return ""
+
+	case token.AND_AND, token.OR_OR:
+		if left != TypeBool || right != TypeBool {
+			a.errorf(diagnostics.TokenSpan(op), diagnostics.InvalidOperand, "operator %s requires bool operands, got %s and %s", op.Lexeme, left, right)
+			return ""
+		}
+		return TypeBool
+
+	default:
+		a.errorf(diagnostics.TokenSpan(op), diagnostics.InvalidOperand, "unsupported binary operator %s", op.Type)
+		return ""
+	}
+}
+
+func (a *Analyzer) callType(node *ast.CallExpr) Type {
+	name := node.Callee.Lexeme
+	argTypes := make([]Type, len(node.Arguments))
+	for i, arg := range node.Arguments {
+		argTypes[i] = a.exprType(arg)
+	}
+
+	if target, ok := conversions[name]; ok {
+		if len(argTypes) != 1 {
+			a.errorf(diagnostics.NodeSpan(node), diagnostics.ArgumentCount, "built-in function %q expects 1 args, got %d", name, len(argTypes))
+			return ""
+		}
+		if argTypes[0] != "" && !isNumeric(argTypes[0]) {
+			a.errorf(diagnostics.NodeSpan(node.Arguments[0]), diagnostics.TypeMismatch, "built-in function %q requires a numeric argument, got %s", name, argTypes[0])
+			return ""
+		}
+		return target
+	}
+	if _, ok := collectionBuiltins[name]; ok {
+		return a.builtinType(node, argTypes)
+	}
+
+	sig, ok := a.functions[name]
+	if !ok {
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.UndeclaredName, "function %q is not declared", name)
+		return ""
+	}
+
+	if len(argTypes) != len(sig.Params) {
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.ArgumentCount, "function %q expects %d args, got %d", name, len(sig.Params), len(argTypes))
+	} else {
+		for i, got := range argTypes {
+			if got != "" && sig.Params[i] != "" && !assignable(got, sig.Params[i]) {
+				a.errorf(diagnostics.NodeSpan(node.Arguments[i]), diagnostics.TypeMismatch, "argument %d of %q must be %s, got %s", i+1, name, sig.Params[i], got)
+			}
+		}
+	}
+
+	switch len(sig.Returns) {
+	case 0:
+		return TypeNil
+	case 1:
+		return sig.Returns[0]
+	default:
+		return tupleOf(sig.Returns)
+	}
+}
+
+// builtinType checks a call to one of the collectionBuiltins and returns its
+// result type
+func (a *Analyzer) builtinType(node *ast.CallExpr, argTypes []Type) Type {
+	name := node.Callee.Lexeme
+	want := collectionBuiltins[name]
+	if len(argTypes) != want {
+		a.errorf(diagnostics.NodeSpan(node), diagnostics.ArgumentCount, "built-in function %q expects %d args, got %d", name, want, len(argTypes))
+		return ""
+	}
+	for _, typ := range argTypes {
+		if typ == "" {
+			return ""
+		}
+	}
+
+	elem, isList := listElem(argTypes[0])
+	key, _, isMap := mapTypes(argTypes[0])
+	switch name {
+	case "len":
+		if !isList && !isMap && argTypes[0] != TypeString {
+			a.errorf(diagnostics.NodeSpan(node.Arguments[0]), diagnostics.TypeMismatch, "built-in function \"len\" requires a list, map or string argument, got %s", argTypes[0])
+			return ""
+		}
+		return TypeInt
+	case "has", "delete", "keys":
+		return a.mapBuiltinType(node, argTypes, key, isMap)
+	}
+
+	switch {
+	case !isList:
+		a.errorf(diagnostics.NodeSpan(node.Arguments[0]), diagnostics.TypeMismatch, "built-in function \"append\" requires a list argument, got %s", argTypes[0])
+		return ""
+	case argTypes[0] == emptyList:
+		return listOf(argTypes[1])
+	case !assignable(argTypes[1], elem):
+		a.errorf(diagnostics.NodeSpan(node.Arguments[1]), diagnostics.TypeMismatch, "cannot append %s value to %s", argTypes[1], argTypes[0])
+		return ""
+	default:
+		return argTypes[0]
+	}
+}
+
+// mapBuiltinType checks a call to has, delete or keys, whose first argument is a
+// map with keys of type key, and returns its result type
+func (a *Analyzer) mapBuiltinType(node *ast.CallExpr, argTypes []Type, key Type, isMap bool) Type {
+	name := node.Callee.Lexeme
+	if !isMap {
+		a.errorf(diagnostics.NodeSpan(node.Arguments[0]), diagnostics.TypeMismatch, "built-in function %q requires a map argument, got %s", name, argTypes[0])
+		return ""
+	}
+	if name == "keys" {
+		if argTypes[0] == emptyMap {
+			return emptyList
+		}
+		return listOf(key)
+	}
+
+	if argTypes[0] != emptyMap && !assignable(argTypes[1], key) {
+		a.errorf(diagnostics.NodeSpan(node.Arguments[1]), diagnostics.TypeMismatch, "map key must be %s, got %s", key, argTypes[1])
+		return ""
+	}
+	if name == "has" {
+		return TypeBool
+	}
+	return TypeNil
+}
+
+// checkFor checks a for loop, whose variable is only declared for the body.
+// Top-level loop variables cannot reuse the name of any global of the program,
+// which could otherwise be declared by a statement after the loop.
+func (a *Analyzer) checkFor(node *ast.ForStmt) {
+	itemType := a.iterationType(node.Iterable)
+
+	name := node.Name.Lexeme
+	scope := a.locals
+	if scope == nil {
+		scope = a.globals
+	}
+	if _, declared := scope[name]; declared {
+		a.errorf(diagnostics.TokenSpan(node.Name), diagnostics.Redeclaration, "loop variable %q is already declared", name)
+	} else {
+		scope[name] = itemType
+		defer delete(scope, name)
+	}
+
+	a.loopDepth++
+	a.checkStmt(node.Body)
+	a.loopDepth--
+}
+
+// iterationType returns the type of the values taken by the variable of a for
+// loop over iterable: ints for ranges, the items of lists and tuples and the
+// keys of maps
+func (a *Analyzer) iterationType(iterable ast.Expr) Type {
+	if r, ok := iterable.(*ast.RangeExpr); ok {
+		for _, bound := range []ast.Expr{r.Low, r.High, r.Step} {
+			if bound == nil {
+				continue
+			}
+			what := "bound"
+			if bound == r.Step {
+				what = "step"
+			}
+			if typ := a.exprType(bound); typ != "" && typ != TypeInt {
+				a.errorf(diagnostics.NodeSpan(bound), diagnostics.TypeMismatch, "range %s must be int, got %s", what, typ)
+			}
+		}
+		if step, ok := r.Step.(*ast.IntLiteral); ok && step.Value == 0 {
+			a.errorf(diagnostics.NodeSpan(step), diagnostics.InvalidOperand, "range step must not be zero")
+		}
+		return TypeInt
+	}
+
+	typ := a.exprType(iterable)
+	if typ == "" {
+		return ""
+	}
+	if elem, isList := listElem(typ); isList {
+		if typ == emptyList {
+			a.errorf(diagnostics.NodeSpan(iterable), diagnostics.InvalidOperand, "cannot iterate over an empty list literal")
+			return ""
+		}
+		return elem
+	}
+	if key, _, isMap := mapTypes(typ); isMap {
+		if typ == emptyMap {
+			a.errorf(diagnostics.NodeSpan(iterable), diagnostics.InvalidOperand, "cannot iterate over an empty map literal")
+			return ""
+		}
+		return key
+	}
+	if items, isTuple := tupleItems(typ); isTuple {
+		for _, item := range items[1:] {
+			if item != items[0] {
+				a.errorf(diagnostics.NodeSpan(iterable), diagnostics.InvalidOperand, "cannot iterate over %s value, its items must all have the same type", typ)
+				return ""
+			}
+		}
+		return items[0]
+	}
+	a.errorf(diagnostics.NodeSpan(iterable), diagnostics.InvalidOperand, "cannot iterate over %s value, expected a range, list, tuple or map", typ)
+	return ""
+}
+
+func (a *Analyzer) declareVariable(name token.Token, typ Type) {
+	if a.locals != nil {
+		if _, exists := a.locals[name.Lexeme]; exists {
+			a.errorf(diagnostics.TokenSpan(name), diagnostics.Redeclaration, "local variable %q already declared", name.Lexeme)
+			return
+		}
+		a.locals[name.Lexeme] = typ
+		return
+	}
+
+	if previous, exists := a.globals[name.Lexeme]; exists && previous != typ {
+		a.errorf(diagnostics.TokenSpan(name), diagnostics.Redeclaration, "variable %q already declared as %s", name.Lexeme, previous)
+		return
+	}
+	a.globals[name.Lexeme] = typ
+}
+
+// lookup resolves a variable name. Function bodies see their locals and every
+// top-level global; top-level code only sees globals declared before the use.
+func (a *Analyzer) lookup(name string) (Type, bool) {
+	if a.locals != nil {
+		if typ, ok := a.locals[name]; ok {
+			return typ, true
+		}
+		if typ, ok := a.hoisted[name]; ok {
+			return typ, true
+		}
+	}
+	typ, ok := a.globals[name]
+	return typ, ok
+}
+
+// listItemType returns the item type of the list type typ of expr, reporting
+// an error naming the action, such as "index", when typ is not the type of a
+// list with known items
+func (a *Analyzer) listItemType(expr ast.Expr, typ Type, action string) Type {
+	if typ == "" {
+		return ""
+	}
+	elem, ok := listElem(typ)
+	switch {
+	case !ok:
+		a.errorf(diagnostics.NodeSpan(expr), diagnostics.InvalidOperand, "cannot %s %s value, expected a list", action, typ)
+		return ""
+	case typ == emptyList:
+		a.errorf(diagnostics.NodeSpan(expr), diagnostics.InvalidOperand, "cannot %s an empty list literal", action)
+		return ""
+	default:
+		return elem
+	}
+}
+
+func (a *Analyzer) resolveType(expr ast.TypeExpr) (Type, bool) {
+	typ, invalid := typeOf(expr)
+	switch node := invalid.(type) {
+	case nil:
+		return typ, true
+	case *ast.MapType:
+		a.errorf(diagnostics.NodeSpan(node.Key), diagnostics.UnknownType, "invalid map key type %s, expected int, bool or string", node.Key)
+	default:
+		a.errorf(diagnostics.NodeSpan(invalid), diagnostics.UnknownType, "unknown type %q", invalid.String())
+	}
+	return "", false
+}
+
+// typeOf returns the type written as expr. When expr uses a name that is not a
+// type, or a map type whose keys cannot be map keys, typeOf returns that part
+// of expr instead.
+func typeOf(expr ast.TypeExpr) (Type, ast.TypeExpr) {
+	switch node := expr.(type) {
+	case *ast.NamedType:
+		typ, ok := typeNames[node.Name.Lexeme]
+		if !ok {
+			return "", node
+		}
+		return typ, nil
+	case *ast.TupleType:
+		items := make([]Type, len(node.Items))
+		for i, item := range node.Items {
+			typ, invalid := typeOf(item)
+			if invalid != nil {
+				return "", invalid
+			}
+			items[i] = typ
+		}
+		return tupleOf(items), nil
+	case *ast.ListType:
+		elem, invalid := typeOf(node.Elem)
+		if invalid != nil {
+			return "", invalid
+		}
+		return listOf(elem), nil
+	case *ast.MapType:
+		key, invalid := typeOf(node.Key)
+		if invalid != nil {
+			return "", invalid
+		}
+		if !isMapKey(key) {
+			return "", node
+		}
+		val, invalid := typeOf(node.Value)
+		if invalid != nil {
+			return "", invalid
+		}
+		return mapOf(key, val), nil
+	default:
+		panic("unsupported type expression")
+	}
+}
+
+func (a *Analyzer) errorf(span diagnostics.Span, code diagnostics.Code, format string, args ...any) *diagnostics.Diagnostic {
+	d := diagnostics.Errorf(code, span, format, args...)
+	a.errs = append(a.errs, d)
+	return d
+}
+
+// terminates reports whether the statement always ends with a return, so a function
+// body ending with it cannot fall through to the missing-return runtime error.
+func terminates(stmt ast.Stmt) bool {
+	switch node := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BlockStmt:
+		return len(node.Statements) > 0 && terminates(node.Statements[len(node.Statements)-1])
+	case *ast.IfStmt:
+		return node.Else != nil && terminates(node.Then) && terminates(node.Else)
+	case *ast.WhileStmt:
+		cond, ok := node.Condition.(*ast.BoolLiteral)
+		return ok && cond.Value && !breaks(node.Body)
+	default:
+		return false
+	}
+}
+
+// breaks reports whether the statement contains a break that leaves the loop
+// enclosing it; breaks inside nested loops only leave those loops.
+func breaks(stmt ast.Stmt) bool {
+	switch node := stmt.(type) {
+	case *ast.BreakStmt:
+		return true
+	case *ast.BlockStmt:
+		for _, inner := range node.Statements {
+			if breaks(inner) {
+				return true
+			}
+		}
+		return false
+	case *ast.IfStmt:
+		return breaks(node.Then) || (node.Else != nil && breaks(node.Else))
+	default:
+		return false
+	}
+}
+
+func isNumeric(t Type) bool {
+	return t == TypeInt || t == TypeFloat
 }
 
-func (a *Analyzer) Analyze(program *ast.Program) []error {
-	_ = program
-	// Step 1 infra: semantic rules will be implemented incrementally.
-	return nil
+// tupleOf returns the type of tuples with items of the given types, written as
+// in the source, like (int, bool)
+func tupleOf(types []Type) Type {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return Type("(" + strings.Join(names, ", ") + ")")
+}
+
+// tupleItems returns the item types of a tuple type built by tupleOf and
+// reports whether t is a tuple type
+func tupleItems(t Type) ([]Type, bool) {
+	s := string(t)
+	if !strings.HasPrefix(s, "(") {
+		return nil, false
+	}
+
+	var items []Type
+	depth, start := 0, 1
+	for i := 1; i < len(s)-1; i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, Type(s[start:i]))
+				start = i + len(", ")
+			}
+		}
+	}
+	return append(items, Type(s[start:len(s)-1])), true
+}
+
+func listOf(elem Type) Type {
+	return "list[" + elem + "]"
+}
+
+// listElem returns the item type of a list type and reports whether t is a list type
+func listElem(t Type) (Type, bool) {
+	elem, ok := strings.CutPrefix(string(t), "list[")
+	if !ok {
+		return "", false
+	}
+	return Type(strings.TrimSuffix(elem, "]")), true
+}
+
+func mapOf(key, val Type) Type {
+	return "map[" + key + "]" + val
+}
+
+// mapTypes returns the key and value types of a map type and reports whether t
+// is a map type
+func mapTypes(t Type) (key, val Type, ok bool) {
+	s, ok := strings.CutPrefix(string(t), "map[")
+	if !ok {
+		return "", "", false
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				return Type(s[:i]), Type(s[i+1:]), true
+			}
+			depth--
+		}
+	}
+	return "", "", false
+}
+
+// isMapKey reports whether values of type t can be used as map keys
+func isMapKey(t Type) bool {
+	return t == TypeInt || t == TypeBool || t == TypeString
+}
+
+// assignable reports whether a value of type from can be used where a value of
+// type to is expected. They must be the same type, except that the empty list
+// and map literals fit any list and map type, also as an item of a tuple.
+func assignable(from, to Type) bool {
+	if from == to {
+		return true
+	}
+	if _, isList := listElem(to); isList && from == emptyList {
+		return true
+	}
+	if _, _, isMap := mapTypes(to); isMap && from == emptyMap {
+		return true
+	}
+
+	fromItems, fromTuple := tupleItems(from)
+	toItems, toTuple := tupleItems(to)
+	if !fromTuple || !toTuple || len(fromItems) != len(toItems) {
+		return false
+	}
+	for i := range fromItems {
+		if !assignable(fromItems[i], toItems[i]) {
+			return false
+		}
+	}
+	return true
 }
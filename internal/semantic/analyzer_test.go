@@ -0,0 +1,234 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/diagnostics"
+	"github.com/rafa-ribeiro/brasalang/internal/parser"
+)
+
+func TestAnalyzeAcceptsWellTypedProgram(t *testing.T) {
+	src := `
+	limit int = 10
+	def divmod(a int, b int) -> (int, int) {
+		return a / b, a - (a / b) * b
+	}
+	def scale(x int, factor float) -> float {
+		if x > limit {
+			return float(limit) * factor
+		} else {
+			return float(x) * factor
+		}
+	}
+	def log_all() {
+		msg string = "done"
+		msg += "!"
+	}
+	def digits(n int) -> int {
+		tens int, _ = divmod(n, 10)
+		return tens
+	}
+	ratio float = scale(4, 0.5)
+	ok bool = ratio >= 1.5 && "a" < "b"
+	divmod(7, 2)
+	log_all()
+	q int, r int = divmod(7, 2)
+	q + r + digits(42)
+	pair (int, (bool, string)) = (q, (ok, "x"))
+	first int, rest (bool, string) = pair
+	flag bool = rest.0 && pair.1.1 == "x" && pair == (1, (true, "x"))
+	def total(xs list[int]) -> int {
+		sum int = 0
+		i int = 0
+		while i < len(xs) {
+			sum += xs[i]
+			i += 1
+		}
+		return sum
+	}
+	nums list[int] = []
+	nums = append(append(nums, 1), 2)
+	nums[0] *= 3
+	grid (list[list[int]], string) = ([nums, nums[1:], []], "g")
+	size int = total(nums[:1]) + len(grid.0[1]) + len("abc")
+	ages map[string]int = {"ana": 30,
+		"bia": 25,
+	}
+	ages["caio"] = 41
+	ages["ana"] += 1
+	delete(ages, "bia")
+	names list[string] = keys(ages)
+	found bool = has(ages, "ana") && len(ages) == 2 && ages != {}
+	index map[int]list[string] = {1: names, 2: []}
+	grouped map[bool]map[int]list[string] = {true: index, false: {}}
+	def evens(limit int) -> list[int] {
+		out list[int] = []
+		for i in 0..=limit step 2 {
+			append(out, i)
+		}
+		for i in limit..0 step -1 {
+			i += 1
+		}
+		return out
+	}
+	for name in names {
+		found = found && len(name) > 0
+	}
+	for b in grouped {
+		found = found || b
+	}
+	for n in (1, 2, 3) {
+		if n == 2 {
+			continue
+		}
+		size += n
+	}
+	for name in keys(ages) {
+		break
+	}
+	for later in 0..2 {
+		size += later
+	}
+	later string = "declared after the loop"
+	`
+	errs := analyze(t, src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", errs)
+	}
+}
+
+func TestAnalyzeReportsTypeErrors(t *testing.T) {
+	cases := map[string]string{
+		"a int = true\n":                                         "cannot use bool value as int",
+		"def f(x int) -> bool {\n return x\n}\n":                 "return value 1 must be bool, got int",
+		"def f() -> (int, bool) {\n return 1\n}\n":               "return expects 2 value(s), got 1",
+		"def f() {\n return 1\n}\n":                              "void function",
+		"def f(x int) -> int {\n if x > 0 {\n return x\n }\n}\n": "must return a value on every path",
+		"def f() -> int {\n while true {\n break\n }\n}\nf()\n":  "must return a value on every path",
+		"def f(x int) {\n}\nf(true)\n":                           "argument 1 of \"f\" must be int, got bool",
+		"def f(x int) {\n}\nf()\n":                               "expects 1 args, got 0",
+		"1 + true\n":                                             "operator + is not defined for int and bool",
+		"\"a\" - \"b\"\n":                                        "operator - is not defined for string and string",
+		"1 == \"a\"\n":                                           "cannot compare int with string",
+		"!1\n":                                                   "operator ! requires bool operand",
+		"1 && true\n":                                            "requires bool operands",
+		"if 1 {\n}\n":                                            "if condition must be bool, got int",
+		"x unknown = 1\n":                                        "unknown type \"unknown\"",
+		"y + 1\n":                                                "identifier \"y\" is not declared",
+		"missing(1)\n":                                           "function \"missing\" is not declared",
+		"n int = 1\nn += 0.5\n":                                  "cannot assign float value to variable \"n\" of type int",
+		"int(\"1\")\n":                                           "requires a numeric argument",
+		"break\n":                                                "only allowed inside loops",
+		"def f() -> (int, int) {\n return 1, 2\n}\na int, b int, c int = f()\n": "cannot destructure 2 values into 3 variables",
+		"def f() -> (int, int) {\n return 1, 2\n}\na int, b bool = f()\n":       "cannot use int value as bool in declaration of \"b\"",
+		"def f() -> int {\n return 1\n}\na int, b int = f()\n":                  "cannot destructure int value",
+		"a int, _ = 1\n":                                       "cannot destructure int value",
+		"t (int, bool) = (1, 2)\n":                             "cannot use (int, int) value as (int, bool)",
+		"t (int, bool) = (1, true)\nt.2\n":                     "index 2 out of range for (int, bool)",
+		"n int = 1\nn.0\n":                                     "cannot index int value with .0, expected a tuple",
+		"(1, true) == (1, 2)\n":                                "cannot compare (int, bool) with (int, int)",
+		"(1, 2) < (1, 3)\n":                                    "operator < is not defined for (int, int) and (int, int)",
+		"def f(p (int, int)) -> (int, bool) {\n return p\n}\n": "function \"f\" must return (int, bool), got (int, int)",
+		"def f(p (int, int)) {\n}\nf((1, 2, 3))\n":             "argument 1 of \"f\" must be (int, int), got (int, int, int)",
+		"t (int, nope) = (1, 2)\n":                             "unknown type \"nope\"",
+		"a (int, int), b int = (1, 2)\n":                       "cannot use int value as (int, int) in declaration of \"a\"",
+		"m map[float]int = {}\n":                               "invalid map key type float, expected int, bool or string",
+		"m map[string]int = {\"a\": 1, 2: 3}\n":                "map keys must all be string, got int",
+		"m map[string]int = {\"a\": 1, \"b\": true}\n":         "map values must all be int, got bool",
+		"len({[1]: 2})\n":                                      "map key must be int, bool or string, got list[int]",
+		"m map[string]int = {}\nm[1]\n":                        "map key must be string, got int",
+		"m map[string]int = {}\nm[\"a\"] = true\n":             "cannot assign bool value to item of type int",
+		"m map[int]int = {}\nm[1:2]\n":                         "cannot slice map[int]int value, expected a list",
+		"has([1], 1)\n":                                        "built-in function \"has\" requires a map argument, got list[int]",
+		"m map[int]bool = {}\ndelete(m, true)\n":               "map key must be int, got bool",
+		"1[0]\n":                                               "cannot index int value, expected a list or map",
+		"for i in 0..true {\n}\n":                              "range bound must be int, got bool",
+		"for i in 0..3 step 0.5 {\n}\n":                        "range step must be int, got float",
+		"for i in 0..3 step 0 {\n}\n":                          "range step must not be zero",
+		"for x in 3 {\n}\n":                                    "cannot iterate over int value, expected a range, list, tuple or map",
+		"for x in (1, \"a\") {\n}\n":                           "cannot iterate over (int, string) value, its items must all have the same type",
+		"for x in [] {\n}\n":                                   "cannot iterate over an empty list literal",
+		"for x in [1] {\n x = \"a\"\n}\n":                      "cannot assign string value to variable \"x\" of type int",
+		"for i in 0..3 {\n}\ni\n":                              "identifier \"i\" is not declared",
+		"for i in 0..3 {\n for i in 0..3 {\n }\n}\n":           "loop variable \"i\" is already declared",
+		"def f(n int) {\n for n in 0..3 {\n }\n}\n":            "loop variable \"n\" is already declared",
+		"def keys(n int) {\n}\n":                               "shadows a built-in function",
+	}
+
+	for src, want := range cases {
+		errs := analyze(t, src)
+		if len(errs) == 0 {
+			t.Fatalf("expected semantic error containing %q for %q", want, src)
+		}
+		if !strings.Contains(errs[0].Error(), want) {
+			t.Fatalf("expected semantic error containing %q for %q, got %v", want, src, errs)
+		}
+	}
+}
+
+func TestAnalyzeReportsEveryErrorWithPosition(t *testing.T) {
+	errs := analyze(t, "a int = true\nb bool = 1\n")
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	first, second := errs[0].Span, errs[1].Span
+	if first.Start.Line != 1 || first.Start.Column != 9 || first.End.Column != 13 {
+		t.Fatalf("unexpected span for first error: %+v", first)
+	}
+	if second.Start.Line != 2 || second.Start.Column != 10 || errs[1].Code != diagnostics.TypeMismatch {
+		t.Fatalf("unexpected second error: %v", errs[1])
+	}
+}
+
+func TestAnalyzeReportsUnknownParameterTypeOnce(t *testing.T) {
+	errs := analyze(t, "def f(x bar, y int) {\n}\nf(1, 2)\n")
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown type \"bar\"") {
+		t.Fatalf("expected a single unknown type error, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestAnalyzeRelatesRedeclaredFunction(t *testing.T) {
+	errs := analyze(t, "def f() {\n}\ndef f() {\n}\n")
+
+	if len(errs) != 1 || errs[0].Code != diagnostics.Redeclaration {
+		t.Fatalf("expected a single redeclaration error, got %v", errs)
+	}
+	if len(errs[0].Related) != 1 || errs[0].Related[0].Span.Start.Line != 1 {
+		t.Fatalf("expected related note at the first declaration, got %+v", errs[0].Related)
+	}
+}
+
+func TestAnalyzerDiscardsDeclarationsOfFailedPrograms(t *testing.T) {
+	a := New()
+	check := func(src string) diagnostics.List {
+		return a.Analyze(parser.NewFromSource(src).ParseProgram())
+	}
+
+	if errs := check("count int = 1\n"); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if errs := check("other int = true\n"); len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	if errs := check("count = count + 1\n"); len(errs) > 0 {
+		t.Fatalf("expected earlier declaration to be kept, got %v", errs)
+	}
+	if errs := check("other = 1\n"); len(errs) != 1 || errs[0].Code != diagnostics.UndeclaredName {
+		t.Fatalf("expected declaration of failed program to be discarded, got %v", errs)
+	}
+}
+
+func analyze(t *testing.T, src string) diagnostics.List {
+	t.Helper()
+
+	p := parser.NewFromSource(src)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.Errors())
+	}
+
+	return New().Analyze(program)
+}
@@ -9,41 +9,60 @@ const (
 	NEWLINE Type = "NEWLINE"
 
 	// Literals
-	IDENT Type = "IDENT"
-	INT   Type = "INT"
+	IDENT  Type = "IDENT"
+	INT    Type = "INT"
+	FLOAT  Type = "FLOAT"
+	STRING Type = "STRING"
 
 	// Keywords
-	TRUE   Type = "TRUE"
-	FALSE  Type = "FALSE"
-	IF     Type = "IF"
-	ELSE   Type = "ELSE"
-	DEF    Type = "DEF"
-	RETURN Type = "RETURN"
-	NIL    Type = "NIL"
+	TRUE     Type = "TRUE"
+	FALSE    Type = "FALSE"
+	IF       Type = "IF"
+	ELSE     Type = "ELSE"
+	WHILE    Type = "WHILE"
+	FOR      Type = "FOR"
+	IN       Type = "IN"
+	BREAK    Type = "BREAK"
+	CONTINUE Type = "CONTINUE"
+	DEF      Type = "DEF"
+	RETURN   Type = "RETURN"
+	NIL      Type = "NIL"
 
 	// Delimiters
-	LPAREN Type = "LPAREN"
-	RPAREN Type = "RPAREN"
-	LBRACE Type = "LBRACE"
-	RBRACE Type = "RBRACE"
-	COMMA  Type = "COMMA"
-	ARROW  Type = "ARROW"
+	LPAREN   Type = "LPAREN"
+	RPAREN   Type = "RPAREN"
+	LBRACE   Type = "LBRACE"
+	RBRACE   Type = "RBRACE"
+	LBRACKET Type = "LBRACKET"
+	RBRACKET Type = "RBRACKET"
+	COMMA    Type = "COMMA"
+	DOT      Type = "DOT"
+	DOT_DOT  Type = "DOT_DOT"
+	COLON    Type = "COLON"
+	ARROW    Type = "ARROW"
 
 	// Operators
-	PLUS        Type = "PLUS"
-	MINUS       Type = "MINUS"
-	STAR        Type = "STAR"
-	SLASH       Type = "SLASH"
-	NOT         Type = "NOT"
-	EQUAL       Type = "EQUAL"
-	EQUAL_EQUAL Type = "EQUAL_EQUAL"
-	NOT_EQUAL   Type = "NOT_EQUAL"
-	GREATER     Type = "GREATER"
-	GREATER_EQ  Type = "GREATER_EQ"
-	LESS        Type = "LESS"
-	LESS_EQ     Type = "LESS_EQ"
-	AND_AND     Type = "AND_AND"
-	OR_OR       Type = "OR_OR"
+	PLUS          Type = "PLUS"
+	MINUS         Type = "MINUS"
+	STAR          Type = "STAR"
+	SLASH         Type = "SLASH"
+	PERCENT       Type = "PERCENT"
+	NOT           Type = "NOT"
+	EQUAL         Type = "EQUAL"
+	PLUS_EQUAL    Type = "PLUS_EQUAL"
+	MINUS_EQUAL   Type = "MINUS_EQUAL"
+	STAR_EQUAL    Type = "STAR_EQUAL"
+	SLASH_EQUAL   Type = "SLASH_EQUAL"
+	PERCENT_EQUAL Type = "PERCENT_EQUAL"
+	EQUAL_EQUAL   Type = "EQUAL_EQUAL"
+	NOT_EQUAL     Type = "NOT_EQUAL"
+	GREATER       Type = "GREATER"
+	GREATER_EQ    Type = "GREATER_EQ"
+	LESS          Type = "LESS"
+	LESS_EQ       Type = "LESS_EQ"
+	AND_AND       Type = "AND_AND"
+	OR_OR         Type = "OR_OR"
+	DOT_DOT_EQUAL Type = "DOT_DOT_EQUAL"
 )
 
 type Position struct {
@@ -51,20 +70,42 @@ type Position struct {
 	Column int
 }
 
+type TriviaKind string
+
+const (
+	LINE_COMMENT  TriviaKind = "LINE_COMMENT"
+	BLOCK_COMMENT TriviaKind = "BLOCK_COMMENT"
+)
+
+// Trivia is source text that has no meaning for the parser, such as comments.
+// It is kept on the tokens so tooling can recover it without lexing again.
+type Trivia struct {
+	Kind     TriviaKind
+	Text     string // Raw text, including the comment delimiters
+	Position Position
+}
+
 type Token struct {
 	Type     Type
 	Lexeme   string
 	Position Position
+	Leading  []Trivia // Comments found before the token, including whole comment lines above it
+	Trailing []Trivia // Comments after the token on the same line
 }
 
 var keywords = map[string]Type{
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"def":    DEF,
-	"return": RETURN,
-	"nil":    NIL,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"def":      DEF,
+	"return":   RETURN,
+	"nil":      NIL,
 }
 
 func LookupIdent(ident string) Type {
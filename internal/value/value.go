@@ -1,6 +1,10 @@
 package value
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type Kind byte
 
@@ -9,12 +13,59 @@ const (
 	BoolKind
 	NilKind
 	TupleKind
+	StringKind
+	FloatKind
+	ListKind
+	MapKind
 )
 
+func (k Kind) String() string {
+	switch k {
+	case IntKind:
+		return "int"
+	case BoolKind:
+		return "bool"
+	case NilKind:
+		return "nil"
+	case TupleKind:
+		return "tuple"
+	case StringKind:
+		return "string"
+	case FloatKind:
+		return "float"
+	case ListKind:
+		return "list"
+	case MapKind:
+		return "map"
+	default:
+		return "unknown"
+	}
+}
+
+// IsNumeric reports whether the kind is int or float
+func (k Kind) IsNumeric() bool {
+	return k == IntKind || k == FloatKind
+}
+
+// IsMapKey reports whether values of the kind can be used as map keys
+func (k Kind) IsMapKey() bool {
+	return k == IntKind || k == BoolKind || k == StringKind
+}
+
 type Value struct {
 	Kind  Kind
 	I     int64
+	F     float64
 	B     bool
+	S     string
+	Items []Value
+	L     *List // items of a list, shared by every copy of the value
+	M     *Map  // entries of a map, shared by every copy of the value
+}
+
+// List holds the items of a list value. Lists live on the heap, so assigning a
+// list or passing it to a function shares its items instead of copying them.
+type List struct {
 	Items []Value
 }
 
@@ -25,6 +76,13 @@ func NewInt(v int64) Value {
 	}
 }
 
+func NewFloat(v float64) Value {
+	return Value{
+		Kind: FloatKind,
+		F:    v,
+	}
+}
+
 func NewBool(v bool) Value {
 	return Value{
 		Kind: BoolKind,
@@ -32,6 +90,13 @@ func NewBool(v bool) Value {
 	}
 }
 
+func NewString(v string) Value {
+	return Value{
+		Kind: StringKind,
+		S:    v,
+	}
+}
+
 func NewNil() Value {
 	return Value{Kind: NilKind}
 }
@@ -42,6 +107,93 @@ func NewTuple(items []Value) Value {
 	return Value{Kind: TupleKind, Items: out}
 }
 
+// NewList returns a list holding a copy of items
+func NewList(items []Value) Value {
+	out := make([]Value, len(items))
+	copy(out, items)
+	return Value{Kind: ListKind, L: &List{Items: out}}
+}
+
+// Map holds the entries of a map value in insertion order. Like lists, maps
+// live on the heap and are shared by every copy of the value. Keys must be of a
+// kind for which IsMapKey is true.
+type Map struct {
+	Keys    []Value // keys in insertion order
+	Values  []Value // value of the key at the same position in Keys
+	indexes map[mapKey]int
+}
+
+// mapKey identifies a map key, with bools stored as 0 or 1 in i
+type mapKey struct {
+	kind Kind
+	i    int64
+	s    string
+}
+
+func keyOf(v Value) mapKey {
+	switch v.Kind {
+	case IntKind:
+		return mapKey{kind: IntKind, i: v.I}
+	case BoolKind:
+		if v.B {
+			return mapKey{kind: BoolKind, i: 1}
+		}
+		return mapKey{kind: BoolKind}
+	case StringKind:
+		return mapKey{kind: StringKind, s: v.S}
+	default:
+		panic(fmt.Sprintf("%s value cannot be a map key", v.Kind))
+	}
+}
+
+// NewMap returns an empty map
+func NewMap() Value {
+	return Value{Kind: MapKind, M: &Map{indexes: map[mapKey]int{}}}
+}
+
+// Len returns the number of entries of the map
+func (m *Map) Len() int {
+	return len(m.Keys)
+}
+
+// Get returns the value of key and reports whether the map has it
+func (m *Map) Get(key Value) (Value, bool) {
+	i, ok := m.indexes[keyOf(key)]
+	if !ok {
+		return Value{}, false
+	}
+	return m.Values[i], true
+}
+
+// Set stores v as the value of key. A new key is placed after every other one.
+func (m *Map) Set(key, v Value) {
+	k := keyOf(key)
+	if i, ok := m.indexes[k]; ok {
+		m.Values[i] = v
+		return
+	}
+	m.indexes[k] = len(m.Keys)
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, v)
+}
+
+// Delete removes key from the map, keeping the order of the other keys, and
+// reports whether the map had it
+func (m *Map) Delete(key Value) bool {
+	k := keyOf(key)
+	i, ok := m.indexes[k]
+	if !ok {
+		return false
+	}
+	delete(m.indexes, k)
+	m.Keys = append(m.Keys[:i], m.Keys[i+1:]...)
+	m.Values = append(m.Values[:i], m.Values[i+1:]...)
+	for _, later := range m.Keys[i:] {
+		m.indexes[keyOf(later)]--
+	}
+	return true
+}
+
 func (v Value) String() string {
 	switch v.Kind {
 	case IntKind:
@@ -51,8 +203,113 @@ func (v Value) String() string {
 	case NilKind:
 		return "nil"
 	case TupleKind:
-		return fmt.Sprintf("%v", v.Items)
+		return "(" + joinItems(v.Items) + ")"
+	case StringKind:
+		return v.S
+	case FloatKind:
+		// keep a decimal point so floats are never printed like ints
+		out := strconv.FormatFloat(v.F, 'g', -1, 64)
+		if !strings.ContainsAny(out, ".eIN") {
+			out += ".0"
+		}
+		return out
+	case ListKind:
+		return "[" + joinItems(v.L.Items) + "]"
+	case MapKind:
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, key := range v.M.Keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(itemString(key) + ": " + itemString(v.M.Values[i]))
+		}
+		b.WriteByte('}')
+		return b.String()
 	default:
 		return "unknown"
 	}
 }
+
+// joinItems renders the items of a tuple or list separated by commas
+func joinItems(items []Value) string {
+	var b strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(itemString(item))
+	}
+	return b.String()
+}
+
+// itemString renders a value held by a collection, quoting strings so their
+// commas are not mistaken for separators
+func itemString(v Value) string {
+	if v.Kind == StringKind {
+		return strconv.Quote(v.S)
+	}
+	return v.String()
+}
+
+// Equal reports whether a and b hold the same value. Ints and floats compare by
+// numeric value, tuples and lists compare item by item, maps compare their
+// entries regardless of order and values of any other different kinds are never
+// equal. As in IEEE 754, NaN is not equal to anything.
+func Equal(a, b Value) bool {
+	if a.Kind != b.Kind {
+		if a.Kind.IsNumeric() && b.Kind.IsNumeric() {
+			return toFloat(a) == toFloat(b)
+		}
+		return false
+	}
+
+	switch a.Kind {
+	case IntKind:
+		return a.I == b.I
+	case FloatKind:
+		return a.F == b.F
+	case BoolKind:
+		return a.B == b.B
+	case NilKind:
+		return true
+	case StringKind:
+		return a.S == b.S
+	case TupleKind:
+		return equalItems(a.Items, b.Items)
+	case ListKind:
+		return equalItems(a.L.Items, b.L.Items)
+	case MapKind:
+		if a.M.Len() != b.M.Len() {
+			return false
+		}
+		for i, key := range a.M.Keys {
+			other, ok := b.M.Get(key)
+			if !ok || !Equal(a.M.Values[i], other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func equalItems(a, b []Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func toFloat(v Value) float64 {
+	if v.Kind == FloatKind {
+		return v.F
+	}
+	return float64(v.I)
+}
@@ -0,0 +1,61 @@
+package vm
+
+import "math"
+
+// Checked int64 arithmetic: results are either exact or the instruction fails
+// with a runtime error, instead of wrapping around.
+
+func addInt(a, b int64) int64 {
+	c := a + b
+	if (c > a) != (b > 0) {
+		overflow(a, "+", b)
+	}
+	return c
+}
+
+func subInt(a, b int64) int64 {
+	c := a - b
+	if (c < a) != (b > 0) {
+		overflow(a, "-", b)
+	}
+	return c
+}
+
+func mulInt(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		overflow(a, "*", b)
+	}
+	return c
+}
+
+func divInt(a, b int64) int64 {
+	if b == 0 {
+		raise(ErrDivisionByZero, "division by zero")
+	}
+	if a == math.MinInt64 && b == -1 {
+		overflow(a, "/", b)
+	}
+	return a / b
+}
+
+func modInt(a, b int64) int64 {
+	if b == 0 {
+		raise(ErrDivisionByZero, "modulo by zero")
+	}
+	return a % b
+}
+
+func negateInt(a int64) int64 {
+	if a == math.MinInt64 {
+		raise(ErrOverflow, "integer overflow: -(%d)", a)
+	}
+	return -a
+}
+
+func overflow(a int64, op string, b int64) {
+	raise(ErrOverflow, "integer overflow: %d %s %d", a, op, b)
+}
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestCheckedIntArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() int64
+		want int64
+		err  string
+	}{
+		{"add", func() int64 { return addInt(40, 2) }, 42, ""},
+		{"add negative", func() int64 { return addInt(math.MinInt64+1, -1) }, math.MinInt64, ""},
+		{"add overflow", func() int64 { return addInt(math.MaxInt64, 1) }, 0, "integer overflow: 9223372036854775807 + 1"},
+		{"add underflow", func() int64 { return addInt(math.MinInt64, -1) }, 0, "integer overflow: -9223372036854775808 + -1"},
+		{"sub", func() int64 { return subInt(2, 44) }, -42, ""},
+		{"sub overflow", func() int64 { return subInt(math.MinInt64, 1) }, 0, "integer overflow: -9223372036854775808 - 1"},
+		{"sub overflow negative", func() int64 { return subInt(0, math.MinInt64) }, 0, "integer overflow: 0 - -9223372036854775808"},
+		{"mul", func() int64 { return mulInt(-6, 7) }, -42, ""},
+		{"mul zero", func() int64 { return mulInt(math.MinInt64, 0) }, 0, ""},
+		{"mul overflow", func() int64 { return mulInt(math.MaxInt64/2+1, 2) }, 0, "integer overflow: 4611686018427387904 * 2"},
+		{"mul min by -1", func() int64 { return mulInt(-1, math.MinInt64) }, 0, "integer overflow: -1 * -9223372036854775808"},
+		{"div", func() int64 { return divInt(-7, 2) }, -3, ""},
+		{"div by zero", func() int64 { return divInt(1, 0) }, 0, "division by zero"},
+		{"div min by -1", func() int64 { return divInt(math.MinInt64, -1) }, 0, "integer overflow: -9223372036854775808 / -1"},
+		{"mod", func() int64 { return modInt(-7, 3) }, -1, ""},
+		{"mod min by -1", func() int64 { return modInt(math.MinInt64, -1) }, 0, ""},
+		{"mod by zero", func() int64 { return modInt(7, 0) }, 0, "modulo by zero"},
+		{"negate", func() int64 { return negateInt(math.MaxInt64) }, -math.MaxInt64, ""},
+		{"negate min", func() int64 { return negateInt(math.MinInt64) }, 0, "integer overflow: -(-9223372036854775808)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int64
+			var err string
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Sprint(r)
+					}
+				}()
+				got = tt.fn()
+			}()
+
+			if err != tt.err || (err == "" && got != tt.want) {
+				t.Fatalf("got %d, %q; want %d, %q", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/rafa-ribeiro/brasalang/internal/token"
+)
+
+// Classes of runtime errors, matched with errors.Is against a *RuntimeError
+var (
+	ErrTypeMismatch    = errors.New("type mismatch")
+	ErrDivisionByZero  = errors.New("division by zero")
+	ErrOverflow        = errors.New("integer overflow")
+	ErrMissingReturn   = errors.New("missing return")
+	ErrInvalidBytecode = errors.New("invalid bytecode")
+	ErrIndexRange      = errors.New("index out of range")
+	ErrKeyNotFound     = errors.New("key not found")
+	ErrInvalidRange    = errors.New("invalid range")
+)
+
+// fault is the panic value of an instruction failing with a classified error
+type fault struct {
+	class   error
+	message string
+}
+
+func (f *fault) Error() string { return f.message }
+func (f *fault) Unwrap() error { return f.class }
+
+// raise fails the current instruction with an error of the given class
+func raise(class error, format string, args ...any) {
+	panic(&fault{class: class, message: fmt.Sprintf(format, args...)})
+}
+
+// mainFunction names the top-level code of a chunk in stack traces
+const mainFunction = "<main>"
+
+// TraceEntry is one call in the Brasa stack trace of a RuntimeError
+type TraceEntry struct {
+	Function string         // name of the function, or <main> for top-level code
+	Offset   int            // offset of the instruction executing in this function
+	Position token.Position // source position of that instruction, zero when the chunk has none
+}
+
+// Location describes where the entry is, by source position when known or by offset
+func (e TraceEntry) Location() string {
+	if e.Position == (token.Position{}) {
+		return fmt.Sprintf("offset %04d", e.Offset)
+	}
+	return fmt.Sprintf("%d:%d", e.Position.Line, e.Position.Column)
+}
+
+// RuntimeError is returned by Run when the program fails. Trace lists the
+// active calls from the innermost one to the top-level code.
+type RuntimeError struct {
+	Message  string
+	Offset   int            // offset of the failing instruction
+	Position token.Position // source position of the failing instruction, zero when unknown
+	Trace    []TraceEntry
+	Err      error // cause of the failure, one of the Err values when it is classified
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Position == (token.Position{}) {
+		return fmt.Sprintf("%s (at offset %04d)", e.Message, e.Offset)
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// StackTrace renders the trace with one call per line
+func (e *RuntimeError) StackTrace() string {
+	var b strings.Builder
+	for _, entry := range e.Trace {
+		fmt.Fprintf(&b, "  at %s (%s)\n", entry.Function, entry.Location())
+	}
+	return b.String()
+}
+
+// runtimeError converts the value recovered from a failing instruction into a
+// RuntimeError and clears the stack and call frames, so the VM can run again.
+func (vm *VM) runtimeError(recovered any) *RuntimeError {
+	var msg string
+	var cause error
+	switch r := recovered.(type) {
+	case runtime.Error:
+		msg = strings.TrimPrefix(r.Error(), "runtime error: ")
+	case error:
+		msg, cause = r.Error(), r
+	default:
+		msg = fmt.Sprint(r)
+	}
+
+	err := &RuntimeError{Message: msg, Offset: vm.opStart, Err: cause}
+	err.Position, _ = vm.chunk.PositionAt(vm.opStart)
+
+	// the caller of each frame is suspended at the OP_CALL that created it
+	offset := vm.opStart
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		name := fmt.Sprintf("<function %d>", frame.fnIndex)
+		if frame.fnIndex < len(vm.chunk.Functions) {
+			name = vm.chunk.Functions[frame.fnIndex].Name
+		}
+		err.Trace = append(err.Trace, vm.traceEntry(name, offset))
+		offset = frame.callIP
+	}
+	err.Trace = append(err.Trace, vm.traceEntry(mainFunction, offset))
+
+	vm.frames = vm.frames[:0]
+	vm.stack.Truncate(0)
+	vm.ip = 0
+	return err
+}
+
+func (vm *VM) traceEntry(function string, offset int) TraceEntry {
+	pos, _ := vm.chunk.PositionAt(offset)
+	return TraceEntry{Function: function, Offset: offset, Position: pos}
+}
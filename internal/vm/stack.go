@@ -16,7 +16,7 @@ func (s *Stack) PushAll(vals []value.Value) {
 
 func (s *Stack) Pop() value.Value {
 	if len(s.values) == 0 {
-		panic("Stack Underflow")
+		panic("stack underflow")
 	}
 
 	lastIndex := len(s.values) - 1
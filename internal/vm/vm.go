@@ -1,13 +1,19 @@
 package vm
 
 import (
+	"cmp"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
 	"github.com/rafa-ribeiro/brasalang/internal/value"
 )
 
 type callFrame struct {
+	callIP   int // Offset of the OP_CALL that created this frame
 	returnIP int // Where to return after function call
 	base     int // Base index in the stack for this function's local variables
 	fnIndex  int // Index of the function in execution
@@ -16,6 +22,7 @@ type callFrame struct {
 type VM struct {
 	stack   Stack           // Store the values in execution
 	ip      int             // Points to the current bytecode instruction being executed
+	opStart int             // Offset of the instruction being executed, reported by runtime errors
 	chunk   *bytecode.Chunk // Current chunk of bytecode (or block of code) being executed
 	globals []value.Value   // Global variables storage
 	frames  []callFrame     // Call stack frames for function calls
@@ -25,31 +32,52 @@ func New() *VM {
 	return &VM{}
 }
 
-func (vm *VM) Run(chunk *bytecode.Chunk) {
+// Run executes chunk from its first instruction. Globals defined by previous
+// runs are kept, while the stack and call frames start empty.
+//
+// Instructions report failures by panicking; Run recovers them and returns a
+// *RuntimeError, leaving the VM ready for another Run.
+func (vm *VM) Run(chunk *bytecode.Chunk) (err error) {
 	vm.chunk = chunk
 	vm.ip = 0
 	vm.frames = vm.frames[:0]
+	vm.stack.Truncate(0)
 
-	for vm.ip < len(vm.chunk.Code) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = vm.runtimeError(recovered)
+		}
+	}()
 
+	for vm.ip < len(vm.chunk.Code) {
+		vm.opStart = vm.ip
 		op := bytecode.OpCode(vm.chunk.Code[vm.ip])
 		vm.ip++
 
 		switch op {
 		case bytecode.OP_CONST:
-			vm.opConst()
+			vm.opConst(vm.readUint8())
+
+		case bytecode.OP_CONST_LONG:
+			vm.opConst(vm.readUint24())
 
 		case bytecode.OP_ADD:
-			vm.binaryIntOp(func(a, b int64) int64 { return a + b })
+			vm.opAdd()
 
 		case bytecode.OP_SUB:
-			vm.binaryIntOp(func(a, b int64) int64 { return a - b })
+			vm.binaryArithOp(op, subInt, func(a, b float64) float64 { return a - b })
 
 		case bytecode.OP_MUL:
-			vm.binaryIntOp(func(a, b int64) int64 { return a * b })
+			vm.binaryArithOp(op, mulInt, func(a, b float64) float64 { return a * b })
 
 		case bytecode.OP_DIV:
-			vm.binaryIntOp(func(a, b int64) int64 { return a / b })
+			vm.binaryArithOp(op, divInt, func(a, b float64) float64 { return a / b })
+
+		case bytecode.OP_MOD:
+			vm.binaryArithOp(op, modInt, math.Mod)
+
+		case bytecode.OP_NEGATE:
+			vm.opNegate()
 
 		case bytecode.OP_TRUE:
 			vm.stack.Push(value.NewBool(true))
@@ -57,59 +85,131 @@ func (vm *VM) Run(chunk *bytecode.Chunk) {
 		case bytecode.OP_FALSE:
 			vm.stack.Push(value.NewBool(false))
 
-		case bytecode.OP_EQUAL:
-			vm.binaryCompareOp(func(a, b int64) bool { return a == b })
-
-		case bytecode.OP_NOT_EQUAL:
-			vm.binaryCompareOp(func(a, b int64) bool { return a != b })
-
-		case bytecode.OP_GREATER:
-			vm.binaryCompareOp(func(a, b int64) bool { return a > b })
-
-		case bytecode.OP_LESS:
-			vm.binaryCompareOp(func(a, b int64) bool { return a < b })
-
-		case bytecode.OP_GREATER_EQUAL:
-			vm.binaryCompareOp(func(a, b int64) bool { return a >= b })
-
-		case bytecode.OP_LESS_EQUAL:
-			vm.binaryCompareOp(func(a, b int64) bool { return a <= b })
+		case bytecode.OP_EQUAL, bytecode.OP_NOT_EQUAL, bytecode.OP_GREATER, bytecode.OP_LESS, bytecode.OP_GREATER_EQUAL, bytecode.OP_LESS_EQUAL:
+			vm.binaryCompareOp(op)
 
 		case bytecode.OP_NOT:
 			vm.opNot()
 
-		case bytecode.OP_AND:
-			vm.binaryBoolOp(func(a, b bool) bool { return a && b })
+		case bytecode.OP_TO_INT:
+			vm.opToInt()
 
-		case bytecode.OP_OR:
-			vm.binaryBoolOp(func(a, b bool) bool { return a || b })
+		case bytecode.OP_TO_FLOAT:
+			vm.opToFloat()
 
 		case bytecode.OP_JUMP:
-			vm.opJump()
+			vm.opJump(int(vm.readUint16()))
+
+		case bytecode.OP_JUMP_LONG:
+			vm.opJump(vm.readUint24())
 
 		case bytecode.OP_JUMP_IF_FALSE:
-			vm.opJumpIfFalse()
+			vm.opJumpIf(false)
+
+		case bytecode.OP_JUMP_IF_TRUE:
+			vm.opJumpIf(true)
+
+		case bytecode.OP_LOOP:
+			vm.opLoop()
 
 		case bytecode.OP_DEFINE_GLOBAL:
-			vm.opDefineGlobal()
+			vm.opDefineGlobal(vm.readUint8())
+
+		case bytecode.OP_DEFINE_GLOBAL_LONG:
+			vm.opDefineGlobal(vm.readUint24())
 
 		case bytecode.OP_GET_GLOBAL:
-			vm.opGetGlobal()
+			vm.opGetGlobal(vm.readUint8())
+
+		case bytecode.OP_GET_GLOBAL_LONG:
+			vm.opGetGlobal(vm.readUint24())
+
+		case bytecode.OP_SET_GLOBAL:
+			vm.opSetGlobal(vm.readUint8())
+
+		case bytecode.OP_SET_GLOBAL_LONG:
+			vm.opSetGlobal(vm.readUint24())
 
 		case bytecode.OP_DEFINE_LOCAL:
-			vm.opDefineLocal()
+			vm.opDefineLocal(vm.readUint8())
+
+		case bytecode.OP_DEFINE_LOCAL_LONG:
+			vm.opDefineLocal(vm.readUint24())
 
 		case bytecode.OP_GET_LOCAL:
-			vm.opGetLocal()
+			vm.opGetLocal(vm.readUint8())
+
+		case bytecode.OP_GET_LOCAL_LONG:
+			vm.opGetLocal(vm.readUint24())
+
+		case bytecode.OP_SET_LOCAL:
+			vm.opSetLocal(vm.readUint8())
+
+		case bytecode.OP_SET_LOCAL_LONG:
+			vm.opSetLocal(vm.readUint24())
 
 		case bytecode.OP_CALL:
-			vm.opCall()
+			vm.opCall(vm.readUint8(), vm.readUint8())
+
+		case bytecode.OP_CALL_LONG:
+			vm.opCall(vm.readUint24(), vm.readUint8())
 
 		case bytecode.OP_BUILD_TUPLE:
 			vm.opBuildTuple()
 
+		case bytecode.OP_UNPACK_TUPLE:
+			vm.opUnpackTuple(vm.readUint8())
+
+		case bytecode.OP_GET_TUPLE_ITEM:
+			vm.opGetTupleItem(vm.readUint8())
+
+		case bytecode.OP_BUILD_LIST:
+			vm.opBuildList(vm.readUint8())
+
+		case bytecode.OP_GET_INDEX:
+			vm.opGetIndex()
+
+		case bytecode.OP_SET_INDEX:
+			vm.opSetIndex()
+
+		case bytecode.OP_SLICE:
+			vm.opSlice()
+
+		case bytecode.OP_LEN:
+			vm.opLen()
+
+		case bytecode.OP_APPEND:
+			vm.opAppend()
+
+		case bytecode.OP_DUP2:
+			vm.stack.PushAll([]value.Value{vm.stack.Get(vm.stack.Size() - 2), vm.stack.Peek()})
+
+		case bytecode.OP_BUILD_MAP:
+			vm.opBuildMap(vm.readUint8())
+
+		case bytecode.OP_HAS:
+			vm.opHas()
+
+		case bytecode.OP_DELETE:
+			vm.opDelete()
+
+		case bytecode.OP_KEYS:
+			vm.opKeys()
+
+		case bytecode.OP_RANGE:
+			vm.opRange(vm.readUint8() == 1)
+
+		case bytecode.OP_RANGE_NEXT:
+			vm.opRangeNext(int(vm.readUint16()))
+
+		case bytecode.OP_ITER:
+			vm.opIter()
+
+		case bytecode.OP_ITER_NEXT:
+			vm.opIterNext(int(vm.readUint16()))
+
 		case bytecode.OP_RUNTIME_ERROR:
-			panic("function reached end without explicit return")
+			raise(ErrMissingReturn, "function %s reached end without explicit return", vm.currentFunction())
 
 		case bytecode.OP_RETURN:
 			vm.opReturn()
@@ -118,83 +218,218 @@ func (vm *VM) Run(chunk *bytecode.Chunk) {
 			vm.stack.Pop()
 
 		default:
-			panic("unknown opcode")
+			panic(fmt.Sprintf("unknown opcode %d", op))
 		}
 
 	}
+	return nil
+}
+
+// Reset discards every global and leaves the VM as returned by New
+func (vm *VM) Reset() {
+	*vm = VM{}
+}
+
+// currentFunction is the name of the function being executed
+func (vm *VM) currentFunction() string {
+	if len(vm.frames) == 0 {
+		return mainFunction
+	}
+	return vm.chunk.Functions[vm.frames[len(vm.frames)-1].fnIndex].Name
 }
 
 func (vm *VM) StackTop() value.Value {
 	return vm.stack.Peek()
 }
 
-func (vm *VM) opConst() {
-	index := vm.chunk.Code[vm.ip]
-	vm.ip++
+// Result returns the value left on top of the stack when the program ended, if any
+func (vm *VM) Result() (value.Value, bool) {
+	if vm.stack.Size() == 0 {
+		return value.Value{}, false
+	}
+	return vm.stack.Peek(), true
+}
+
+func (vm *VM) opConst(index int) {
 	constant := vm.chunk.Constants[index]
 	vm.stack.Push(constant)
 }
 
-func (vm *VM) binaryIntOp(op func(int64, int64) int64) {
+// operatorSymbols names the operator of each binary opcode in runtime errors
+var operatorSymbols = map[bytecode.OpCode]string{
+	bytecode.OP_ADD:           "+",
+	bytecode.OP_SUB:           "-",
+	bytecode.OP_MUL:           "*",
+	bytecode.OP_DIV:           "/",
+	bytecode.OP_MOD:           "%",
+	bytecode.OP_GREATER:       ">",
+	bytecode.OP_LESS:          "<",
+	bytecode.OP_GREATER_EQUAL: ">=",
+	bytecode.OP_LESS_EQUAL:    "<=",
+}
+
+// opAdd concatenates two strings or adds two numbers
+func (vm *VM) opAdd() {
+	a := vm.stack.Get(vm.stack.Size() - 2)
+	b := vm.stack.Peek()
+
+	if a.Kind == value.StringKind && b.Kind == value.StringKind {
+		vm.stack.Truncate(vm.stack.Size() - 2)
+		vm.stack.Push(value.NewString(a.S + b.S))
+		return
+	}
+
+	vm.binaryArithOp(bytecode.OP_ADD, addInt, func(a, b float64) float64 { return a + b })
+}
+
+// binaryArithOp applies intOp when both operands are ints. If any of them is a
+// float, the other one is promoted and floatOp is used instead.
+func (vm *VM) binaryArithOp(op bytecode.OpCode, intOp func(int64, int64) int64, floatOp func(float64, float64) float64) {
 	b := vm.stack.Pop()
 	a := vm.stack.Pop()
 
-	result := op(a.I, b.I)
-	vm.stack.Push(value.NewInt(result))
+	if !a.Kind.IsNumeric() || !b.Kind.IsNumeric() {
+		raise(ErrTypeMismatch, "operator %s is not defined for %s and %s", operatorSymbols[op], a.Kind, b.Kind)
+	}
+
+	if a.Kind == value.FloatKind || b.Kind == value.FloatKind {
+		vm.stack.Push(value.NewFloat(floatOp(toFloat(a), toFloat(b))))
+		return
+	}
+
+	vm.stack.Push(value.NewInt(intOp(a.I, b.I)))
 }
 
-func (vm *VM) binaryCompareOp(op func(int64, int64) bool) {
+// binaryCompareOp checks values of any kind for equality and orders strings
+// lexicographically and numbers by value, promoting ints to float on mixed
+// operands. A NaN operand is only ever not equal.
+func (vm *VM) binaryCompareOp(op bytecode.OpCode) {
 	b := vm.stack.Pop()
 	a := vm.stack.Pop()
 
-	result := op(a.I, b.I)
+	switch op {
+	case bytecode.OP_EQUAL:
+		vm.stack.Push(value.NewBool(value.Equal(a, b)))
+		return
+	case bytecode.OP_NOT_EQUAL:
+		vm.stack.Push(value.NewBool(!value.Equal(a, b)))
+		return
+	}
+
+	var c int
+	switch {
+	case a.Kind == value.StringKind && b.Kind == value.StringKind:
+		c = strings.Compare(a.S, b.S)
+	case a.Kind == value.IntKind && b.Kind == value.IntKind:
+		c = cmp.Compare(a.I, b.I)
+	case a.Kind.IsNumeric() && b.Kind.IsNumeric():
+		x, y := toFloat(a), toFloat(b)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			vm.stack.Push(value.NewBool(false))
+			return
+		}
+		c = cmp.Compare(x, y)
+	default:
+		raise(ErrTypeMismatch, "operator %s is not defined for %s and %s", operatorSymbols[op], a.Kind, b.Kind)
+	}
+
+	var result bool
+	switch op {
+	case bytecode.OP_GREATER:
+		result = c > 0
+	case bytecode.OP_LESS:
+		result = c < 0
+	case bytecode.OP_GREATER_EQUAL:
+		result = c >= 0
+	case bytecode.OP_LESS_EQUAL:
+		result = c <= 0
+	}
+
 	vm.stack.Push(value.NewBool(result))
 }
 
-func (vm *VM) opNot() {
+func (vm *VM) opToInt() {
 	v := vm.stack.Pop()
 
-	if v.Kind != value.BoolKind {
-		panic("OP_NOT requires boolean")
+	switch v.Kind {
+	case value.IntKind:
+		vm.stack.Push(v)
+	case value.FloatKind:
+		if math.IsNaN(v.F) || v.F >= math.MaxInt64 || v.F < math.MinInt64 {
+			raise(ErrOverflow, "float %s cannot be converted to int", v)
+		}
+		vm.stack.Push(value.NewInt(int64(v.F)))
+	default:
+		raise(ErrTypeMismatch, "int() requires a numeric argument, got %s", v.Kind)
 	}
+}
 
-	vm.stack.Push(value.NewBool(!v.B))
+func (vm *VM) opToFloat() {
+	v := vm.stack.Pop()
+
+	if !v.Kind.IsNumeric() {
+		raise(ErrTypeMismatch, "float() requires a numeric argument, got %s", v.Kind)
+	}
+
+	vm.stack.Push(value.NewFloat(toFloat(v)))
 }
 
-// TODO rafael: Change this to implement short-circuit
-func (vm *VM) binaryBoolOp(op func(bool, bool) bool) {
-	b, a := vm.stack.Pop(), vm.stack.Pop()
+func (vm *VM) opNegate() {
+	v := vm.stack.Pop()
 
-	if a.Kind != value.BoolKind || b.Kind != value.BoolKind {
-		panic("Boolean operation requires bool operands")
+	switch v.Kind {
+	case value.IntKind:
+		vm.stack.Push(value.NewInt(negateInt(v.I)))
+	case value.FloatKind:
+		vm.stack.Push(value.NewFloat(-v.F))
+	default:
+		raise(ErrTypeMismatch, "operator - is not defined for %s", v.Kind)
 	}
+}
 
-	result := op(a.B, b.B)
-	vm.stack.Push(value.NewBool(result))
+func toFloat(v value.Value) float64 {
+	if v.Kind == value.FloatKind {
+		return v.F
+	}
+	return float64(v.I)
 }
 
-func (vm *VM) opJump() {
-	offset := vm.readUint16()
-	vm.ip += int(offset)
+func (vm *VM) opNot() {
+	v := vm.stack.Pop()
+
+	if v.Kind != value.BoolKind {
+		raise(ErrTypeMismatch, "operator ! requires bool, got %s", v.Kind)
+	}
+
+	vm.stack.Push(value.NewBool(!v.B))
 }
 
-func (vm *VM) opJumpIfFalse() {
+func (vm *VM) opJump(offset int) {
+	vm.ip += offset
+}
+
+// opJumpIf jumps when the condition on top of the stack equals when. The
+// condition stays on the stack, as && and || use it as their result.
+func (vm *VM) opJumpIf(when bool) {
 	offset := vm.readUint16()
 
 	condition := vm.stack.Peek()
 
 	if condition.Kind != value.BoolKind {
-		panic("OP_JUMP_IF_FALSE requires boolean")
+		raise(ErrTypeMismatch, "condition must be bool, got %s", condition.Kind)
 	}
 
-	if !condition.B {
+	if condition.B == when {
 		vm.ip += int(offset)
 	}
 }
 
-func (vm *VM) opDefineGlobal() {
-	slot := int(vm.chunk.Code[vm.ip])
-	vm.ip++
+func (vm *VM) opLoop() {
+	offset := vm.readUint16()
+	vm.ip -= int(offset)
+}
+
+func (vm *VM) opDefineGlobal(slot int) {
 
 	for len(vm.globals) <= slot {
 		vm.globals = append(vm.globals, value.Value{})
@@ -203,22 +438,27 @@ func (vm *VM) opDefineGlobal() {
 	vm.globals[slot] = vm.stack.Pop()
 }
 
-func (vm *VM) opGetGlobal() {
-	slot := int(vm.chunk.Code[vm.ip])
-	vm.ip++
+func (vm *VM) opGetGlobal(slot int) {
 
 	if slot >= len(vm.globals) {
-		panic("global slot not initialized")
+		raise(ErrInvalidBytecode, "global slot not initialized")
 	}
 
 	vm.stack.Push(vm.globals[slot])
 }
 
-func (vm *VM) opDefineLocal() {
-	slot := int(vm.chunk.Code[vm.ip])
-	vm.ip++
+func (vm *VM) opSetGlobal(slot int) {
+
+	if slot >= len(vm.globals) {
+		raise(ErrInvalidBytecode, "global slot not initialized")
+	}
+
+	vm.globals[slot] = vm.stack.Pop()
+}
+
+func (vm *VM) opDefineLocal(slot int) {
 	if len(vm.frames) == 0 {
-		panic("local declaration outside function")
+		raise(ErrInvalidBytecode, "local declaration outside function")
 	}
 	frame := vm.frames[len(vm.frames)-1]
 	idx := frame.base + slot
@@ -232,34 +472,33 @@ func (vm *VM) opDefineLocal() {
 	vm.stack.Set(idx, v)
 }
 
-func (vm *VM) opGetLocal() {
-	slot := int(vm.chunk.Code[vm.ip])
-	vm.ip++
+func (vm *VM) opGetLocal(slot int) {
 	frame := vm.frames[len(vm.frames)-1]
 	vm.stack.Push(vm.stack.Get(frame.base + slot))
 }
 
-func (vm *VM) opCall() {
-	fnIndex := int(vm.chunk.Code[vm.ip])
-	argc := int(vm.chunk.Code[vm.ip+1])
-	vm.ip += 2
+func (vm *VM) opSetLocal(slot int) {
+	frame := vm.frames[len(vm.frames)-1]
+	vm.stack.Set(frame.base+slot, vm.stack.Pop())
+}
+
+func (vm *VM) opCall(fnIndex, argc int) {
 
 	fn := vm.chunk.Functions[fnIndex]
 	if argc != int(fn.Arity) {
-		panic(fmt.Sprintf("function %s expects %d args, got %d", fn.Name, fn.Arity, argc))
+		raise(ErrInvalidBytecode, "function %s expects %d args, got %d", fn.Name, fn.Arity, argc)
 	}
 
 	base := vm.stack.Size() - argc
-	vm.frames = append(vm.frames, callFrame{returnIP: vm.ip, base: base, fnIndex: fnIndex})
-	for i := argc; i < int(fn.LocalCount); i++ {
+	vm.frames = append(vm.frames, callFrame{callIP: vm.opStart, returnIP: vm.ip, base: base, fnIndex: fnIndex})
+	for i := argc; i < fn.LocalCount; i++ {
 		vm.stack.Push(value.Value{})
 	}
-	vm.ip = int(fn.Entry)
+	vm.ip = fn.Entry
 }
 
 func (vm *VM) opBuildTuple() {
-	count := int(vm.chunk.Code[vm.ip])
-	vm.ip++
+	count := vm.readUint8()
 
 	items := make([]value.Value, count)
 	for i := count - 1; i >= 0; i-- {
@@ -269,6 +508,321 @@ func (vm *VM) opBuildTuple() {
 	vm.stack.Push(value.NewTuple(items))
 }
 
+// opUnpackTuple replaces the tuple on top of the stack by its count items
+func (vm *VM) opUnpackTuple(count int) {
+	v := vm.stack.Pop()
+
+	if v.Kind != value.TupleKind {
+		raise(ErrTypeMismatch, "cannot destructure %s value", v.Kind)
+	}
+	if len(v.Items) != count {
+		raise(ErrTypeMismatch, "cannot destructure tuple of %d values into %d variables", len(v.Items), count)
+	}
+
+	vm.stack.PushAll(v.Items)
+}
+
+// opGetTupleItem replaces the tuple on top of the stack by its item at index
+func (vm *VM) opGetTupleItem(index int) {
+	v := vm.stack.Pop()
+
+	if v.Kind != value.TupleKind {
+		raise(ErrTypeMismatch, "cannot index %s value with .%d", v.Kind, index)
+	}
+	if index >= len(v.Items) {
+		raise(ErrTypeMismatch, "index %d out of range for tuple of %d values", index, len(v.Items))
+	}
+
+	vm.stack.Push(v.Items[index])
+}
+
+// opBuildList replaces the count values on top of the stack by a new list holding them
+func (vm *VM) opBuildList(count int) {
+	items := make([]value.Value, count)
+	for i := count - 1; i >= 0; i-- {
+		items[i] = vm.stack.Pop()
+	}
+
+	vm.stack.Push(value.NewList(items))
+}
+
+func (vm *VM) opGetIndex() {
+	index := vm.stack.Pop()
+	collection := vm.stack.Pop()
+
+	if collection.Kind == value.MapKind {
+		v, ok := collection.M.Get(mapKey(index))
+		if !ok {
+			raise(ErrKeyNotFound, "key %s not found in map", quoted(index))
+		}
+		vm.stack.Push(v)
+		return
+	}
+	list := asList(collection, "index")
+	vm.stack.Push(list.Items[listIndex(list, index)])
+}
+
+// opSetIndex stores a value in a list at an existing index, or in a map at
+// any key, adding the key when the map does not have it
+func (vm *VM) opSetIndex() {
+	v := vm.stack.Pop()
+	index := vm.stack.Pop()
+	collection := vm.stack.Pop()
+
+	if collection.Kind == value.MapKind {
+		collection.M.Set(mapKey(index), v)
+		return
+	}
+	list := asList(collection, "assign to an index of")
+	list.Items[listIndex(list, index)] = v
+}
+
+// opSlice replaces a list and its low and high bounds by a new list holding
+// the items from low up to, but not including, high
+func (vm *VM) opSlice() {
+	high := vm.stack.Pop()
+	low := vm.stack.Pop()
+	list := asList(vm.stack.Pop(), "slice")
+
+	from, to := 0, len(list.Items)
+	if low.Kind != value.NilKind {
+		from = int(asInt(low, "slice bound"))
+	}
+	if high.Kind != value.NilKind {
+		to = int(asInt(high, "slice bound"))
+	}
+	if from < 0 || to > len(list.Items) || from > to {
+		raise(ErrIndexRange, "slice bounds [%d:%d] out of range for list of %d items", from, to, len(list.Items))
+	}
+
+	vm.stack.Push(value.NewList(list.Items[from:to]))
+}
+
+func (vm *VM) opLen() {
+	v := vm.stack.Pop()
+
+	switch v.Kind {
+	case value.ListKind:
+		vm.stack.Push(value.NewInt(int64(len(v.L.Items))))
+	case value.MapKind:
+		vm.stack.Push(value.NewInt(int64(v.M.Len())))
+	case value.StringKind:
+		vm.stack.Push(value.NewInt(int64(utf8.RuneCountInString(v.S))))
+	default:
+		raise(ErrTypeMismatch, "len() requires a list, map or string argument, got %s", v.Kind)
+	}
+}
+
+// opAppend adds the value on top of the stack to the end of the list below it.
+// The list is changed in place and stays on the stack as the result.
+func (vm *VM) opAppend() {
+	v := vm.stack.Pop()
+	list := vm.stack.Peek()
+
+	if list.Kind != value.ListKind {
+		raise(ErrTypeMismatch, "append() requires a list argument, got %s", list.Kind)
+	}
+	list.L.Items = append(list.L.Items, v)
+}
+
+func (vm *VM) opBuildMap(count int) {
+	entries := make([]value.Value, 2*count)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i] = vm.stack.Pop()
+	}
+
+	m := value.NewMap()
+	for i := 0; i < len(entries); i += 2 {
+		m.M.Set(mapKey(entries[i]), entries[i+1])
+	}
+	vm.stack.Push(m)
+}
+
+func (vm *VM) opHas() {
+	key := vm.stack.Pop()
+	m := asMap(vm.stack.Pop(), "has")
+
+	_, ok := m.Get(mapKey(key))
+	vm.stack.Push(value.NewBool(ok))
+}
+
+// opDelete removes a key from a map. Deleting a key the map does not have is
+// not an error.
+func (vm *VM) opDelete() {
+	key := vm.stack.Pop()
+	m := asMap(vm.stack.Pop(), "delete")
+
+	m.Delete(mapKey(key))
+	vm.stack.Push(value.NewNil())
+}
+
+func (vm *VM) opKeys() {
+	m := asMap(vm.stack.Pop(), "keys")
+	vm.stack.Push(value.NewList(m.Keys))
+}
+
+// opRange replaces the bounds and step of a range by the state read by
+// OP_RANGE_NEXT: the next value, the last value and the step, which becomes 0
+// once no value is left. Keeping the last value instead of the high bound lets
+// the loop end without computing a value past it, which could overflow.
+func (vm *VM) opRange(inclusive bool) {
+	step := asInt(vm.stack.Pop(), "range step")
+	high := asInt(vm.stack.Pop(), "range bound")
+	low := asInt(vm.stack.Pop(), "range bound")
+	if step == 0 {
+		raise(ErrInvalidRange, "range step must not be zero")
+	}
+
+	last, ok := rangeLast(low, high, step, inclusive)
+	if !ok {
+		step = 0
+	}
+	vm.stack.Push(value.NewInt(low))
+	vm.stack.Push(value.NewInt(last))
+	vm.stack.Push(value.NewInt(step))
+}
+
+// rangeLast returns the last value of the range from low to high by step, and
+// false when the range has no values
+func rangeLast(low, high, step int64, inclusive bool) (int64, bool) {
+	if step > 0 {
+		if !inclusive {
+			if high == math.MinInt64 {
+				return 0, false
+			}
+			high--
+		}
+		if low > high {
+			return 0, false
+		}
+		// the distance can exceed MaxInt64, but always fits a uint64
+		span := uint64(high) - uint64(low)
+		return low + int64(span-span%uint64(step)), true
+	}
+
+	if !inclusive {
+		if high == math.MaxInt64 {
+			return 0, false
+		}
+		high++
+	}
+	if low < high {
+		return 0, false
+	}
+	span := uint64(low) - uint64(high)
+	stride := uint64(-(step + 1)) + 1 // -step, which overflows for MinInt64
+	return low - int64(span-span%stride), true
+}
+
+// opRangeNext pushes the next value of the range state on top of the stack and
+// advances the state, or jumps by offset when the range has no values left
+func (vm *VM) opRangeNext(offset int) {
+	top := vm.stack.Size()
+	step := vm.stack.Get(top - 1).I
+	if step == 0 {
+		vm.ip += offset
+		return
+	}
+
+	current, last := vm.stack.Get(top-3).I, vm.stack.Get(top-2).I
+	if current == last {
+		vm.stack.Set(top-1, value.NewInt(0))
+	} else {
+		vm.stack.Set(top-3, value.NewInt(current+step))
+	}
+	vm.stack.Push(value.NewInt(current))
+}
+
+// opIter starts iterating over the collection on top of the stack. Maps are
+// replaced by a list of their keys, so changing the map in the loop body does
+// not change the keys visited.
+func (vm *VM) opIter() {
+	collection := vm.stack.Pop()
+	switch collection.Kind {
+	case value.ListKind, value.TupleKind:
+	case value.MapKind:
+		collection = value.NewList(collection.M.Keys)
+	default:
+		raise(ErrTypeMismatch, "cannot iterate over %s value", collection.Kind)
+	}
+	vm.stack.Push(collection)
+	vm.stack.Push(value.NewInt(0))
+}
+
+// opIterNext pushes the item of the collection state at the position after it
+// and advances the position, or jumps by offset when the collection has no
+// items left. Items appended to a list by the loop body are visited.
+func (vm *VM) opIterNext(offset int) {
+	top := vm.stack.Size()
+	collection, position := vm.stack.Get(top-2), vm.stack.Get(top-1).I
+
+	var items []value.Value
+	switch collection.Kind {
+	case value.ListKind:
+		items = collection.L.Items
+	case value.TupleKind:
+		items = collection.Items
+	default:
+		raise(ErrTypeMismatch, "cannot iterate over %s value", collection.Kind)
+	}
+	if position >= int64(len(items)) {
+		vm.ip += offset
+		return
+	}
+
+	vm.stack.Set(top-1, value.NewInt(position+1))
+	vm.stack.Push(items[position])
+}
+
+// asMap returns the entries of v, failing when v is not the map argument of
+// the named built-in function
+func asMap(v value.Value, builtin string) *value.Map {
+	if v.Kind != value.MapKind {
+		raise(ErrTypeMismatch, "%s() requires a map argument, got %s", builtin, v.Kind)
+	}
+	return v.M
+}
+
+// mapKey returns key, failing when its kind cannot be used as a map key
+func mapKey(key value.Value) value.Value {
+	if !key.Kind.IsMapKey() {
+		raise(ErrTypeMismatch, "map key must be int, bool or string, got %s", key.Kind)
+	}
+	return key
+}
+
+// quoted renders v for an error message, quoting strings
+func quoted(v value.Value) string {
+	if v.Kind == value.StringKind {
+		return strconv.Quote(v.S)
+	}
+	return v.String()
+}
+
+// asList returns the items of v, failing when v is not a list
+func asList(v value.Value, action string) *value.List {
+	if v.Kind != value.ListKind {
+		raise(ErrTypeMismatch, "cannot %s %s value", action, v.Kind)
+	}
+	return v.L
+}
+
+func asInt(v value.Value, what string) int64 {
+	if v.Kind != value.IntKind {
+		raise(ErrTypeMismatch, "%s must be int, got %s", what, v.Kind)
+	}
+	return v.I
+}
+
+// listIndex returns index as a position of list, failing when it is out of range
+func listIndex(list *value.List, index value.Value) int {
+	i := asInt(index, "list index")
+	if i < 0 || i >= int64(len(list.Items)) {
+		raise(ErrIndexRange, "index %d out of range for list of %d items", i, len(list.Items))
+	}
+	return int(i)
+}
+
 func (vm *VM) opReturn() {
 	ret := vm.stack.Pop()
 	if len(vm.frames) == 0 {
@@ -285,6 +839,18 @@ func (vm *VM) opReturn() {
 	vm.ip = frame.returnIP                   // Return to the instruction after the call
 }
 
+func (vm *VM) readUint8() int {
+	b := vm.chunk.Code[vm.ip]
+	vm.ip++
+	return int(b)
+}
+
+func (vm *VM) readUint24() int {
+	code := vm.chunk.Code[vm.ip : vm.ip+3]
+	vm.ip += 3
+	return int(code[0])<<16 | int(code[1])<<8 | int(code[2])
+}
+
 func (vm *VM) readUint16() uint16 {
 	high := uint16(vm.chunk.Code[vm.ip])
 	low := uint16(vm.chunk.Code[vm.ip+1])
@@ -0,0 +1,392 @@
+package vm
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/rafa-ribeiro/brasalang/internal/bytecode"
+	"github.com/rafa-ribeiro/brasalang/internal/value"
+)
+
+func runError(t *testing.T, machine *VM, chunk *bytecode.Chunk) *RuntimeError {
+	t.Helper()
+
+	var runtimeErr *RuntimeError
+	if err := machine.Run(chunk); !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got %v", err)
+	}
+	return runtimeErr
+}
+
+func TestRunReturnsRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    []byte
+		message string
+		offset  int
+		class   error
+	}{
+		{"stack underflow", []byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_POP), byte(bytecode.OP_POP)}, "stack underflow", 2, nil},
+		{"unknown opcode", []byte{byte(bytecode.OP_TRUE), 0xff}, "unknown opcode 255", 1, nil},
+		{"type error", []byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_TO_FLOAT)}, "float() requires a numeric argument, got bool", 1, ErrTypeMismatch},
+		{"undefined global", []byte{byte(bytecode.OP_GET_GLOBAL), 3}, "global slot not initialized", 0, ErrInvalidBytecode},
+		{"assign undefined global", []byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_SET_GLOBAL), 3}, "global slot not initialized", 1, ErrInvalidBytecode},
+		{"local outside function", []byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_DEFINE_LOCAL), 0}, "local declaration outside function", 1, ErrInvalidBytecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runError(t, New(), &bytecode.Chunk{Code: tt.code})
+			if err.Message != tt.message || err.Offset != tt.offset {
+				t.Fatalf("expected %q at %d, got %q at %d", tt.message, tt.offset, err.Message, err.Offset)
+			}
+			if tt.class != nil && !errors.Is(err, tt.class) {
+				t.Fatalf("expected a %v error, got %v", tt.class, err)
+			}
+			if len(err.Trace) != 1 || err.Trace[0].Function != "<main>" {
+				t.Fatalf("unexpected trace: %+v", err.Trace)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorStackTrace(t *testing.T) {
+	// 0000 OP_JUMP -> 0011
+	// 0003 inner: OP_TRUE, OP_TO_INT, OP_RETURN
+	// 0006 outer: OP_CALL inner, OP_RETURN
+	// 0010 OP_RETURN (unreachable)
+	// 0011 OP_CALL outer
+	chunk := &bytecode.Chunk{
+		Code: []byte{
+			byte(bytecode.OP_JUMP), 0, 8,
+			byte(bytecode.OP_TRUE), byte(bytecode.OP_TO_INT), byte(bytecode.OP_RETURN),
+			byte(bytecode.OP_CALL), 0, 0, byte(bytecode.OP_RETURN),
+			byte(bytecode.OP_RETURN),
+			byte(bytecode.OP_CALL), 1, 0,
+		},
+		Functions: []bytecode.FunctionMeta{
+			{Name: "inner", Entry: 3},
+			{Name: "outer", Entry: 6},
+		},
+	}
+
+	err := runError(t, New(), chunk)
+	want := []TraceEntry{{Function: "inner", Offset: 4}, {Function: "outer", Offset: 6}, {Function: "<main>", Offset: 11}}
+	if len(err.Trace) != len(want) {
+		t.Fatalf("unexpected trace: %+v", err.Trace)
+	}
+	for i := range want {
+		if err.Trace[i] != want[i] {
+			t.Fatalf("unexpected trace: %+v", err.Trace)
+		}
+	}
+	if !strings.Contains(err.StackTrace(), "  at outer (offset 0006)\n") {
+		t.Fatalf("unexpected rendered trace:\n%s", err.StackTrace())
+	}
+}
+
+func TestArityMismatch(t *testing.T) {
+	chunk := &bytecode.Chunk{
+		Code:      []byte{byte(bytecode.OP_CALL), 0, 0},
+		Functions: []bytecode.FunctionMeta{{Name: "pair", Arity: 2}},
+	}
+
+	err := runError(t, New(), chunk)
+	if err.Message != "function pair expects 2 args, got 0" || !errors.Is(err, ErrInvalidBytecode) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFloatToIntOutOfRange(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), -1e19} {
+		chunk := &bytecode.Chunk{
+			Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_TO_INT)},
+			Constants: []value.Value{value.NewFloat(f)},
+		}
+		err := runError(t, New(), chunk)
+		if !errors.Is(err, ErrOverflow) || !strings.HasSuffix(err.Message, "cannot be converted to int") {
+			t.Fatalf("int(%v): unexpected error: %v", f, err)
+		}
+	}
+}
+
+func TestVMRunsAgainAfterError(t *testing.T) {
+	machine := New()
+
+	define := &bytecode.Chunk{Code: []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_DEFINE_GLOBAL), 0}, Constants: []value.Value{value.NewInt(7)}}
+	if err := machine.Run(define); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runError(t, machine, &bytecode.Chunk{Code: []byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_TRUE), 0xff}})
+	if _, ok := machine.Result(); ok {
+		t.Fatal("expected the stack to be cleared after the error")
+	}
+
+	if err := machine.Run(&bytecode.Chunk{Code: []byte{byte(bytecode.OP_GET_GLOBAL), 0}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := machine.StackTop(); result.I != 7 {
+		t.Fatalf("expected global to survive the error, got %v", result)
+	}
+
+	machine.Reset()
+	runError(t, machine, &bytecode.Chunk{Code: []byte{byte(bytecode.OP_GET_GLOBAL), 0}})
+}
+
+func TestOperandKindsAreChecked(t *testing.T) {
+	nilValue, one, text := value.NewNil(), value.NewInt(1), value.NewString("a")
+
+	tests := []struct {
+		name    string
+		left    value.Value
+		right   value.Value
+		op      bytecode.OpCode
+		message string
+	}{
+		{"nil plus int", nilValue, one, bytecode.OP_ADD, "operator + is not defined for nil and int"},
+		{"string minus int", text, one, bytecode.OP_SUB, "operator - is not defined for string and int"},
+		{"bool times bool", value.NewBool(true), value.NewBool(false), bytecode.OP_MUL, "operator * is not defined for bool and bool"},
+		{"string less than int", text, one, bytecode.OP_LESS, "operator < is not defined for string and int"},
+		{"tuple ordering", value.NewTuple([]value.Value{one}), value.NewTuple([]value.Value{one}), bytecode.OP_GREATER_EQUAL, "operator >= is not defined for tuple and tuple"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := &bytecode.Chunk{
+				Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 1, byte(tt.op)},
+				Constants: []value.Value{tt.left, tt.right},
+			}
+
+			err := runError(t, New(), chunk)
+			if err.Message != tt.message || err.Offset != 4 {
+				t.Fatalf("expected %q at 4, got %q at %d", tt.message, err.Message, err.Offset)
+			}
+			if !errors.Is(err, ErrTypeMismatch) {
+				t.Fatalf("expected a type mismatch, got %v", err.Err)
+			}
+		})
+	}
+}
+
+func TestEqualityAcrossKinds(t *testing.T) {
+	pair := func(a, b value.Value) value.Value { return value.NewTuple([]value.Value{a, b}) }
+	one, two := value.NewInt(1), value.NewInt(2)
+	nan := value.NewFloat(math.NaN())
+	nanList := value.NewList([]value.Value{nan})
+	nanMap := value.NewMap()
+	nanMap.M.Set(one, nan)
+
+	tests := []struct {
+		name  string
+		left  value.Value
+		right value.Value
+		equal bool
+	}{
+		{"different bools", value.NewBool(true), value.NewBool(false), false},
+		{"same bools", value.NewBool(false), value.NewBool(false), true},
+		{"nils", value.NewNil(), value.NewNil(), true},
+		{"nil and int zero", value.NewNil(), value.NewInt(0), false},
+		{"bool and int", value.NewBool(false), value.NewInt(0), false},
+		{"int and float", two, value.NewFloat(2), true},
+		{"NaN", nan, nan, false},
+		{"list holding NaN with itself", nanList, nanList, false},
+		{"map holding NaN with itself", nanMap, nanMap, false},
+		{"equal tuples", pair(one, value.NewString("x")), pair(value.NewFloat(1), value.NewString("x")), true},
+		{"different tuples", pair(one, two), pair(two, one), false},
+		{"nested tuples", pair(one, pair(two, value.NewNil())), pair(one, pair(two, value.NewNil())), true},
+		{"tuples of different length", pair(one, two), value.NewTuple([]value.Value{one}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, op := range []bytecode.OpCode{bytecode.OP_EQUAL, bytecode.OP_NOT_EQUAL} {
+				machine := New()
+				chunk := &bytecode.Chunk{
+					Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 1, byte(op)},
+					Constants: []value.Value{tt.left, tt.right},
+				}
+				if err := machine.Run(chunk); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				want := tt.equal == (op == bytecode.OP_EQUAL)
+				if got := machine.StackTop(); got.Kind != value.BoolKind || got.B != want {
+					t.Fatalf("%s: expected %v, got %v", op, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestArithmeticErrorsAreClassified(t *testing.T) {
+	chunk := &bytecode.Chunk{
+		Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 1, byte(bytecode.OP_MOD)},
+		Constants: []value.Value{value.NewInt(1), value.NewInt(0)},
+	}
+	if err := runError(t, New(), chunk); !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("expected division by zero, got %v", err)
+	}
+
+	chunk.Constants = []value.Value{value.NewInt(math.MaxInt64), value.NewInt(2)}
+	chunk.Code[4] = byte(bytecode.OP_MUL)
+	if err := runError(t, New(), chunk); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected overflow, got %v", err)
+	}
+}
+
+func TestUnpackTupleChecksLength(t *testing.T) {
+	pair := value.NewTuple([]value.Value{value.NewInt(1), value.NewBool(true)})
+
+	tests := []struct {
+		constant value.Value
+		count    byte
+		message  string
+	}{
+		{pair, 3, "cannot destructure tuple of 2 values into 3 variables"},
+		{value.NewInt(1), 2, "cannot destructure int value"},
+	}
+	for _, tt := range tests {
+		chunk := &bytecode.Chunk{
+			Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_UNPACK_TUPLE), tt.count},
+			Constants: []value.Value{tt.constant},
+		}
+		err := runError(t, New(), chunk)
+		if err.Message != tt.message || !errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("expected %q, got %v", tt.message, err)
+		}
+	}
+
+	machine := New()
+	chunk := &bytecode.Chunk{
+		Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_UNPACK_TUPLE), 2, byte(bytecode.OP_POP)},
+		Constants: []value.Value{pair},
+	}
+	if err := machine.Run(chunk); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result, _ := machine.Result(); result.Kind != value.IntKind || result.I != 1 {
+		t.Fatalf("expected the first item below the second, got %v", result)
+	}
+}
+
+func TestGetTupleItemChecksIndex(t *testing.T) {
+	pair := value.NewTuple([]value.Value{value.NewInt(1), value.NewString("b")})
+
+	tests := []struct {
+		constant value.Value
+		index    byte
+		message  string
+	}{
+		{pair, 2, "index 2 out of range for tuple of 2 values"},
+		{value.NewInt(1), 0, "cannot index int value with .0"},
+	}
+	for _, tt := range tests {
+		chunk := &bytecode.Chunk{
+			Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_GET_TUPLE_ITEM), tt.index},
+			Constants: []value.Value{tt.constant},
+		}
+		err := runError(t, New(), chunk)
+		if err.Message != tt.message || !errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("expected %q, got %v", tt.message, err)
+		}
+	}
+
+	machine := New()
+	chunk := &bytecode.Chunk{
+		Code:      []byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_GET_TUPLE_ITEM), 1},
+		Constants: []value.Value{pair},
+	}
+	if err := machine.Run(chunk); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result, _ := machine.Result(); result.Kind != value.StringKind || result.S != "b" {
+		t.Fatalf("expected the second item, got %v", result)
+	}
+}
+
+func TestListOpcodesCheckOperandKinds(t *testing.T) {
+	tests := []struct {
+		code    []byte
+		message string
+	}{
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_TRUE), byte(bytecode.OP_APPEND)}, "append() requires a list argument, got int"},
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 0, byte(bytecode.OP_GET_INDEX)}, "cannot index int value"},
+		{[]byte{byte(bytecode.OP_BUILD_LIST), 0, byte(bytecode.OP_TRUE), byte(bytecode.OP_GET_INDEX)}, "list index must be int, got bool"},
+		{[]byte{byte(bytecode.OP_TRUE), byte(bytecode.OP_LEN)}, "len() requires a list, map or string argument, got bool"},
+	}
+	for _, tt := range tests {
+		chunk := &bytecode.Chunk{Code: tt.code, Constants: []value.Value{value.NewInt(1)}}
+		err := runError(t, New(), chunk)
+		if err.Message != tt.message || !errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("expected %q, got %v", tt.message, err)
+		}
+	}
+}
+
+func TestMapOpcodesCheckOperandKinds(t *testing.T) {
+	tests := []struct {
+		code    []byte
+		message string
+	}{
+		{[]byte{byte(bytecode.OP_BUILD_LIST), 0, byte(bytecode.OP_CONST), 0, byte(bytecode.OP_BUILD_MAP), 1}, "map key must be int, bool or string, got list"},
+		{[]byte{byte(bytecode.OP_BUILD_MAP), 0, byte(bytecode.OP_BUILD_LIST), 0, byte(bytecode.OP_GET_INDEX)}, "map key must be int, bool or string, got list"},
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_TRUE), byte(bytecode.OP_HAS)}, "has() requires a map argument, got int"},
+		{[]byte{byte(bytecode.OP_BUILD_LIST), 0, byte(bytecode.OP_KEYS)}, "keys() requires a map argument, got list"},
+	}
+	for _, tt := range tests {
+		chunk := &bytecode.Chunk{Code: tt.code, Constants: []value.Value{value.NewInt(1)}}
+		err := runError(t, New(), chunk)
+		if err.Message != tt.message || !errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("expected %q, got %v", tt.message, err)
+		}
+	}
+}
+
+func TestRangeLastStopsBeforeOverflow(t *testing.T) {
+	tests := []struct {
+		low, high, step int64
+		inclusive       bool
+		last            int64
+		ok              bool
+	}{
+		{0, 10, 3, false, 9, true},
+		{0, 9, 3, false, 6, true},
+		{0, 9, 3, true, 9, true},
+		{3, 3, 1, false, 0, false},
+		{3, 3, 1, true, 3, true},
+		{10, 0, -4, false, 2, true},
+		{0, 10, -1, false, 0, false},
+		{math.MinInt64, math.MaxInt64, math.MaxInt64, true, math.MaxInt64 - 1, true},
+		{math.MaxInt64, math.MinInt64, math.MinInt64, true, -1, true},
+		{0, math.MinInt64, 1, false, 0, false},
+		{0, math.MaxInt64, -1, false, 0, false},
+	}
+	for _, tt := range tests {
+		last, ok := rangeLast(tt.low, tt.high, tt.step, tt.inclusive)
+		if last != tt.last || ok != tt.ok {
+			t.Fatalf("rangeLast(%d, %d, %d, %t) = %d, %t, want %d, %t", tt.low, tt.high, tt.step, tt.inclusive, last, ok, tt.last, tt.ok)
+		}
+	}
+}
+
+func TestIterOpcodesCheckOperandKinds(t *testing.T) {
+	tests := []struct {
+		code    []byte
+		class   error
+		message string
+	}{
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_ITER)}, ErrTypeMismatch, "cannot iterate over int value"},
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_TRUE), byte(bytecode.OP_CONST), 0, byte(bytecode.OP_RANGE), 0}, ErrTypeMismatch, "range bound must be int, got bool"},
+		{[]byte{byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 0, byte(bytecode.OP_CONST), 1, byte(bytecode.OP_RANGE), 1}, ErrInvalidRange, "range step must not be zero"},
+	}
+	for _, tt := range tests {
+		chunk := &bytecode.Chunk{Code: tt.code, Constants: []value.Value{value.NewInt(1), value.NewInt(0)}}
+		err := runError(t, New(), chunk)
+		if err.Message != tt.message || !errors.Is(err, tt.class) {
+			t.Fatalf("expected %q, got %v", tt.message, err)
+		}
+	}
+}